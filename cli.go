@@ -0,0 +1,128 @@
+// code for command line, flags take precedence over config.ini, which takes precedence over default
+
+package main
+
+import (
+	"excel/config"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const usage = `用法：
+  excel [run] [选项]            收集src下的文件到dst
+  excel restore <时间戳> [选项]  用备份恢复dst下的文件
+  excel backups [选项]          列出可恢复的备份
+
+选项：
+`
+
+const (
+	cmdRun     = "run"
+	cmdRestore = "restore"
+	cmdBackups = "backups"
+)
+
+type options struct {
+	command    string
+	args       []string // args of command
+	configPath string
+	src, dst   string
+	tasks      string
+	sequential bool
+	noPause    bool
+	restore    string
+	summary    string // path of json summary
+	period     string
+}
+
+// parseArgs parse subcommand and flags, flags can be given before or after subcommand
+func parseArgs(args []string, output io.Writer) (*options, error) {
+	opts := &options{command: cmdRun}
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		opts.command = args[0]
+		args = args[1:]
+	}
+	fs := flag.NewFlagSet("excel", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.configPath, "config", "", "config file, default is config.ini in work dir")
+	fs.StringVar(&opts.src, "src", "", "src dir, override directory.src of config")
+	fs.StringVar(&opts.dst, "dst", "", "dst dir, override directory.dst of config")
+	fs.StringVar(&opts.tasks, "tasks", "", "tasks to run, such as content,cps, override task of config")
+	fs.BoolVar(&opts.sequential, "sequential", false, "run tasks one by one, override concurrency.enable of config")
+	fs.StringVar(&opts.period, "period", "", "year and month of all src files, such as 2022-03, override file names")
+	fs.BoolVar(&opts.noPause, "no-pause", false, "exit without waiting for enter")
+	fs.StringVar(&opts.summary, "summary-json", "", "write summary of run into this json file")
+	fs.StringVar(&opts.restore, "restore", "", "same as restore command, such as 20211018-150405.000, or 20211018-150405 for the backup made in that second")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	opts.args = fs.Args()
+	if opts.restore != "" {
+		opts.command = cmdRestore
+		opts.args = append([]string{opts.restore}, opts.args...)
+	}
+
+	switch opts.command {
+	case cmdRun, cmdBackups:
+		if len(opts.args) != 0 {
+			return nil, fmt.Errorf("too many arguments for %s: %v", opts.command, opts.args)
+		}
+	case cmdRestore:
+		if len(opts.args) != 1 {
+			return nil, fmt.Errorf("restore needs one timestamp, see backups command")
+		}
+	default:
+		return nil, fmt.Errorf("unknown command: %s", opts.command)
+	}
+	return opts, nil
+}
+
+// apply override conf by flags given
+func (opts *options) apply(conf *config.Config) error {
+	if opts.src != "" {
+		conf.SrcPath = opts.src
+	}
+	if opts.dst != "" {
+		conf.DstPath = opts.dst
+	}
+	if opts.period != "" {
+		if _, err := time.Parse("2006-01", opts.period); err != nil {
+			return fmt.Errorf("invalid period %s, should be like 2022-03", opts.period)
+		}
+		conf.Period = opts.period
+	}
+	if opts.sequential {
+		conf.Concurrent = false
+	}
+	if opts.tasks != "" {
+		taskMap := make(map[string]bool, len(conf.TaskMap))
+		for task := range conf.Schema {
+			taskMap[task] = false
+		}
+		for _, task := range strings.Split(opts.tasks, ",") {
+			task = strings.TrimSpace(task)
+			if task == "" {
+				continue
+			}
+			if _, ok := taskMap[task]; !ok {
+				known := make([]string, 0, len(taskMap))
+				for t := range taskMap {
+					known = append(known, t)
+				}
+				sort.Strings(known)
+				return fmt.Errorf("unknown task %s, tasks are %s", task, strings.Join(known, ","))
+			}
+			taskMap[task] = true
+		}
+		conf.TaskMap = taskMap
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+// code for keep old dst files in backup dir before they are overwritten
+// backup file is named as "<name>_<timestamp><ext>", dst file and ods file written with it are backed up
+// with the same timestamp and restored together, old backups are removed by retention of config
+// an existing backup is never overwritten, timestamp is moved on by a millisecond until the name is free
+
+package collect
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	backupDir        = "backup"
+	backupTimeFormat = "20060102-150405.000"
+)
+
+// formats of backup timestamp, backups made by older versions are in seconds
+var backupTimeFormats = []string{backupTimeFormat, "20060102-150405"}
+
+// Backup is one backup of dst file and the files written with it, made at the same time
+type Backup struct {
+	Timestamp string
+	Time      time.Time
+	Paths     map[string]string // name of dst file, path of its backup
+}
+
+// backupName return backup file name of filename at t
+func backupName(filename string, t time.Time) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "_" + t.Format(backupTimeFormat) + ext
+}
+
+// odsName return name of ods file written beside dst file filename
+func odsName(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ".ods"
+}
+
+// outputs return names of all files written from dst file filename
+func outputs(filename string) []string {
+	return []string{filename, odsName(filename)}
+}
+
+// Backups return backups of dst file filename, the newest first
+func (c *Collect) Backups(filename string) ([]Backup, error) {
+	files, err := os.ReadDir(filepath.Join(c.dstDir, backupDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	byTimestamp := make(map[string]*Backup)
+	for _, output := range outputs(filename) {
+		ext := filepath.Ext(output)
+		prefix := strings.TrimSuffix(output, ext) + "_"
+		for _, file := range files {
+			name := file.Name()
+			if file.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+				continue
+			}
+			timestamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+			t, ok := parseBackupTime(timestamp)
+			if !ok {
+				continue // not a backup made by us
+			}
+			backup, ok := byTimestamp[timestamp]
+			if !ok {
+				backup = &Backup{Timestamp: timestamp, Time: t, Paths: make(map[string]string)}
+				byTimestamp[timestamp] = backup
+			}
+			backup.Paths[output] = filepath.Join(c.dstDir, backupDir, name)
+		}
+	}
+	backups := make([]Backup, 0, len(byTimestamp))
+	for _, backup := range byTimestamp {
+		backups = append(backups, *backup)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+	return backups, nil
+}
+
+func parseBackupTime(timestamp string) (time.Time, bool) {
+	for _, format := range backupTimeFormats {
+		if t, err := time.ParseInLocation(format, timestamp, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// reserveBackup create empty backup files of filenames at the same time t or later,
+// so they are not taken by another run
+func reserveBackup(dir string, filenames []string, t time.Time) (map[string]string, error) {
+	for {
+		paths := make(map[string]string, len(filenames))
+		taken := false
+		for _, filename := range filenames {
+			path := filepath.Join(dir, backupName(filename, t))
+			file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+			if os.IsExist(err) {
+				taken = true
+				break
+			} else if err != nil {
+				removeFiles(paths)
+				return nil, err
+			}
+			paths[filename] = path
+			if err := file.Close(); err != nil {
+				removeFiles(paths)
+				return nil, err
+			}
+		}
+		if !taken {
+			return paths, nil
+		}
+		removeFiles(paths)
+		t = t.Add(time.Millisecond)
+	}
+}
+
+func removeFiles(paths map[string]string) {
+	for _, path := range paths {
+		os.Remove(path)
+	}
+}
+
+// backupDstFiles move dst files into backup dir with the same timestamp, or copy them if keep is true,
+// files not exist are left out
+func (c *Collect) backupDstFiles(filenames []string, keep bool) error {
+	exist := make([]string, 0, len(filenames))
+	for _, filename := range filenames {
+		if _, err := os.Stat(filepath.Join(c.dstDir, filename)); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		exist = append(exist, filename)
+	}
+	if len(exist) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Join(c.dstDir, backupDir), 0755); err != nil {
+		return err
+	}
+	backups, err := reserveBackup(filepath.Join(c.dstDir, backupDir), exist, time.Now())
+	if err != nil {
+		return fmt.Errorf("backup %s: %w", strings.Join(exist, ", "), err)
+	}
+	for i, filename := range exist {
+		path := filepath.Join(c.dstDir, filename)
+		if keep {
+			err = copyFile(path, backups[filename])
+		} else {
+			err = os.Rename(path, backups[filename])
+		}
+		if err != nil {
+			// files moved already are kept in backup dir, only the reserved ones are removed
+			for _, filename := range exist[i:] {
+				os.Remove(backups[filename])
+			}
+			return fmt.Errorf("backup %s: %w", filename, err)
+		}
+	}
+	return nil
+}
+
+// backupOutputs back up files to be written from dst file filename in this run
+func (c *Collect) backupOutputs(filename string) error {
+	filenames := []string{filename}
+	if c.conf.Ods {
+		filenames = append(filenames, odsName(filename))
+	}
+	return c.backupDstFiles(filenames, c.conf.Append)
+}
+
+// pruneBackups remove backups out of retention, a backup is kept if it is one of the newest
+// conf.BackupKeep backups or made in the last conf.BackupDays days, zero means no limit
+func (c *Collect) pruneBackups(filename string) error {
+	if c.conf.BackupKeep <= 0 && c.conf.BackupDays <= 0 {
+		return nil
+	}
+	backups, err := c.Backups(filename)
+	if err != nil {
+		return err
+	}
+	since := time.Now().AddDate(0, 0, -c.conf.BackupDays)
+	for i, backup := range backups {
+		if c.conf.BackupKeep > 0 && i < c.conf.BackupKeep {
+			continue
+		}
+		if c.conf.BackupDays > 0 && backup.Time.After(since) {
+			continue
+		}
+		for _, path := range backup.Paths {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// findBackup return backup of timestamp, a timestamp in seconds also matches the only backup made in that second
+func (c *Collect) findBackup(filename, timestamp string) (*Backup, error) {
+	backups, err := c.Backups(filename)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]*Backup, 0)
+	for i := range backups {
+		if backups[i].Timestamp == timestamp {
+			return &backups[i], nil
+		}
+		if backups[i].Time.Truncate(time.Second).Format("20060102-150405") == timestamp {
+			matched = append(matched, &backups[i])
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return nil, fmt.Errorf("backup %s of %s not found", timestamp, filename)
+	case 1:
+		return matched[0], nil
+	default:
+		timestamps := make([]string, len(matched))
+		for i, backup := range matched {
+			timestamps[i] = backup.Timestamp
+		}
+		return nil, fmt.Errorf("more than one backup of %s made at %s: %s", filename, timestamp, strings.Join(timestamps, ", "))
+	}
+}
+
+// Restore put every file of backup of timestamp back, current files are backed up before replaced,
+// backups are not pruned here, so the one to restore is not removed
+func (c *Collect) Restore(timestamp string) error {
+	filename := "项目立项及实际费用明细.xlsx"
+	backup, err := c.findBackup(filename, timestamp)
+	if err != nil {
+		return err
+	}
+	filenames := make([]string, 0, len(backup.Paths))
+	for _, output := range outputs(filename) {
+		if _, ok := backup.Paths[output]; ok {
+			filenames = append(filenames, output)
+		}
+	}
+	if err := c.backupDstFiles(filenames, true); err != nil {
+		return err
+	}
+	for _, output := range filenames {
+		if err := copyFile(backup.Paths[output], filepath.Join(c.dstDir, output)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,92 @@
+package collect
+
+import (
+	"excel/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupOutputs(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCollect(&config.Config{DstPath: dir, Ods: true})
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+	filename := "项目立项及实际费用明细.xlsx"
+
+	write(filename, "xlsx 1")
+	write(odsName(filename), "ods 1")
+	if err := c.backupOutputs(filename); err != nil {
+		t.Fatal(err)
+	}
+	backups, err := c.Backups(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || len(backups[0].Paths) != 2 {
+		t.Fatalf("backups = %+v, want one backup of xlsx and ods", backups)
+	}
+	for _, name := range outputs(filename) {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s is not moved into backup", name)
+		}
+	}
+	first := backups[0].Timestamp
+
+	write(filename, "xlsx 2")
+	write(odsName(filename), "ods 2")
+	if err := c.Restore(first); err != nil {
+		t.Fatal(err)
+	}
+	if read(filename) != "xlsx 1" || read(odsName(filename)) != "ods 1" {
+		t.Errorf("restored %q and %q, want files of first backup", read(filename), read(odsName(filename)))
+	}
+	// files replaced by restore are backed up together
+	if backups, err = c.Backups(filename); err != nil || len(backups) != 2 || len(backups[0].Paths) != 2 {
+		t.Fatalf("backups = %+v, %v, want two backups of xlsx and ods", backups, err)
+	}
+
+	// a backup can be restored by timestamp in seconds, unless more than one are made in that second
+	seconds := first[:strings.Index(first, ".")]
+	if backups[0].Timestamp[:len(seconds)] == seconds {
+		if err := c.Restore(seconds); err == nil || !strings.Contains(err.Error(), "more than one") {
+			t.Errorf("err = %v, want more than one backup", err)
+		}
+	} else if err := c.Restore(seconds); err != nil {
+		t.Errorf("restore by seconds: %v", err)
+	}
+	if err := c.Restore("20000101-000000"); err == nil {
+		t.Error("no error for backup not found")
+	}
+}
+
+func TestBackupsOldTimestamp(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCollect(&config.Config{DstPath: dir})
+	if err := os.MkdirAll(filepath.Join(dir, backupDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"明细_20211018-150405.xlsx", "明细_20211018-150405.123.ods", "明细_copy.xlsx"} {
+		if err := os.WriteFile(filepath.Join(dir, backupDir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups, err := c.Backups("明细.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || backups[0].Timestamp != "20211018-150405.123" || backups[1].Timestamp != "20211018-150405" {
+		t.Errorf("backups = %+v, want backups in both formats, the newest first", backups)
+	}
+}
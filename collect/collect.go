@@ -1,35 +1,62 @@
 package collect
 
 import (
+	"context"
 	"errors"
 	"excel/config"
 	"fmt"
 	"github.com/xuri/excelize/v2"
+	"golang.org/x/sync/errgroup"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 type Collect struct {
-	conf               *config.Config
-	srcDir, dstDir     string
-	srcFiles, dstFiles map[string]*excelize.File // file_name, fd
-	srcCsvFiles        map[string]*os.File
-	dstFilesMutex      map[string]*sync.Mutex
+	conf           *config.Config
+	srcDir, dstDir string
+	srcFiles       map[string]workbook       // file_name, src workbook
+	dstFiles       map[string]*excelize.File // file_name, fd
+	srcNames       []string                  // names of srcFiles in conf.FileOrder
+	srcHashes      map[string]string         // file_name, hash of content
+	srcPeriods     map[string]period         // file_name, months covered by file
+	srcPeriodErrs  map[string]error          // file_name, why period can not be parsed
+	srcCsvFiles    map[string]*os.File
+	srcFilesMutex  map[string]*sync.Mutex // tasks may read the same src file
+	dstWriters     map[string]*bookWriter // file_name, the only writer of dst file
+	newSheets      map[string]bool        // dst sheets created in this run, only they are formatted
+	orgs           *orgTable              // uid, org, shared by content and mcn
+	orgsOnce       sync.Once
+	orgsErr        error
+	orgConflicts   []*orgConflict        // uid assigned to different orgs, nil if orgs not loaded
+	report         *Report               // src rows not carried over
+	results        []TaskResult          // result of each task in the last run
+	stats          map[string]*TaskStats // task, stats of the running tasks
+	manifest       *manifest             // src files collected into dst file
 }
 
 type Sheet struct {
-	name      string // sheet name
-	start     string // start coordinates value for search
-	row, col  int    // row and col index now
-	file      *excelize.File
-	fileName  string // file name of this sheet
-	fileMutex *sync.Mutex
-	data      [][]string // each col data of each row
-	month     string
-	indexs    []int             // special col index
-	org       map[string]string // uid, org
+	name      string         // sheet name
+	start     string         // start coordinates value for search
+	row, col  int            // row and col index now
+	file      *excelize.File // dst file
+	book      workbook       // src file
+	fileName  string         // file name of this sheet
+	fileMutex *sync.Mutex    // lock of src file when reading
+	header    []string       // header row of src sheet
+	data      [][]string     // each col data of each row
+	period    period         // months covered by src file
+	schema    *config.Schema // column mapping of src sheet
+	cols      map[string]int // field, src col index start from zero
+	dst       map[string]int // field, dst col number start from one
+	org       *orgTable      // uid, org
+	task      string         // task this sheet belongs to
+	report    *Report        // record src rows not carried over
+	styles    map[string]int // value type, style of dst file
+	union     *headerUnion   // header of dst sheet, union of src headers by name
 }
 
 func NewCollect(config *config.Config) *Collect {
@@ -37,10 +64,16 @@ func NewCollect(config *config.Config) *Collect {
 		conf:          config,
 		srcDir:        config.SrcPath,
 		dstDir:        config.DstPath,
-		srcFiles:      make(map[string]*excelize.File),
+		srcFiles:      make(map[string]workbook),
+		srcHashes:     make(map[string]string),
+		srcPeriods:    make(map[string]period),
+		srcPeriodErrs: make(map[string]error),
 		dstFiles:      make(map[string]*excelize.File),
 		srcCsvFiles:   make(map[string]*os.File),
-		dstFilesMutex: make(map[string]*sync.Mutex),
+		srcFilesMutex: make(map[string]*sync.Mutex),
+		dstWriters:    make(map[string]*bookWriter),
+		newSheets:     make(map[string]bool),
+		report:        NewReport(),
 	}
 }
 
@@ -49,17 +82,27 @@ func (c *Collect) loadSrcFiles() error {
 	if err != nil {
 		return err
 	}
+	mtimes := make(map[string]time.Time)
 	for _, file := range files {
-		if strings.HasSuffix(file.Name(), "xlsx") || strings.HasSuffix(file.Name(), "xls") {
-			osf, err := os.OpenFile(c.srcDir+"/"+file.Name(), os.O_RDONLY, os.ModePerm)
+		if strings.HasSuffix(file.Name(), "xlsx") || strings.HasSuffix(file.Name(), "xls") ||
+			strings.HasSuffix(file.Name(), "ods") {
+			info, err := file.Info()
 			if err != nil {
 				return err
 			}
-			f, err := excelize.OpenReader(osf)
+			mtimes[file.Name()] = info.ModTime()
+			content, err := os.ReadFile(c.srcDir + "/" + file.Name())
 			if err != nil {
 				return err
 			}
+			f, err := openWorkbook(content)
+			if err != nil {
+				return fmt.Errorf("%s: %w", file.Name(), err)
+			}
 			c.srcFiles[file.Name()] = f
+			c.srcHashes[file.Name()] = fileHash(content)
+			c.srcFilesMutex[file.Name()] = new(sync.Mutex)
+			c.srcNames = append(c.srcNames, file.Name())
 			// fmt.Println("successfully load", file.Name())
 		} else if strings.HasSuffix(file.Name(), "csv") {
 			f, err := os.OpenFile(c.srcDir+"/"+file.Name(), os.O_RDONLY, os.ModePerm)
@@ -70,10 +113,25 @@ func (c *Collect) loadSrcFiles() error {
 		}
 	}
 
+	c.loadPeriods()
+	sortSrcFiles(c.srcNames, c.conf.FileOrder, mtimes, c.srcPeriods)
 	return nil
 }
 
+// createDstFile create a new dst file, or open the existing one in append mode,
+// the old dst file is backed up either way
 func (c *Collect) createDstFile(filename string) error {
+	if err := c.backupOutputs(filename); err != nil {
+		return err
+	}
+	if err := c.pruneBackups(filename); err != nil {
+		return err
+	}
+	if c.conf.Append {
+		if _, err := os.Stat(c.dstDir + "/" + filename); err == nil {
+			return c.openDstFile(filename)
+		}
+	}
 	f := excelize.NewFile()
 	s, err := os.Stat(filepath.Dir(c.dstDir + "/" + filename))
 	if err != nil && os.IsNotExist(err) {
@@ -86,16 +144,82 @@ func (c *Collect) createDstFile(filename string) error {
 			return fmt.Errorf("path %s is not dir", filepath.Dir(c.dstDir+"/"+filename))
 		}
 	}
-	// TODO: backup old file before save as new file
 	if err := f.SaveAs(c.dstDir + "/" + filename); err != nil {
 		return err
 	}
+	c.manifest = newManifest()
+	c.dstFiles[filename] = f
+	c.dstWriters[filename] = newBookWriter(f)
+	return nil
+}
+
+// openDstFile open existing dst file, sheets and cells not written by tasks are kept as they are
+func (c *Collect) openDstFile(filename string) error {
+	f, err := excelize.OpenFile(c.dstDir + "/" + filename)
+	if err != nil {
+		return err
+	}
+	m, err := loadManifest(f)
+	if err != nil {
+		return fmt.Errorf("read %s of %s: %w", manifestSheet, filename, err)
+	}
+	c.manifest = m
 	c.dstFiles[filename] = f
-	c.dstFilesMutex[filename] = new(sync.Mutex)
+	c.dstWriters[filename] = newBookWriter(f)
 	return nil
 }
 
+// taskOrder is the order to schedule tasks, so sequential run is reproducible
+var taskOrder = []string{"content", "campaign", "cps", "newgame", "mcn"}
+
+// TaskResult is result of one task in a run
+type TaskResult struct {
+	Task     string
+	Err      error // nil if task is successful
+	Duration time.Duration
+	TaskStats
+}
+
+// TaskStats count src files and rows of one task, rows are data rows without header
+type TaskStats struct {
+	Files       []string // src files read by task
+	RowsRead    int      // rows read from src sheets, blank rows not included
+	RowsSkipped int      // rows not carried over, see Report
+	RowsWritten int      // rows written into dst sheet
+}
+
+// tasks return enabled tasks in taskOrder, unknown tasks are sorted at the end
+func (c *Collect) tasks() []string {
+	tasks := make([]string, 0, len(c.conf.TaskMap))
+	for _, task := range taskOrder {
+		if c.conf.TaskMap[task] {
+			tasks = append(tasks, task)
+		}
+	}
+	unknown := make([]string, 0)
+	for task, enabled := range c.conf.TaskMap {
+		known := false
+		for _, t := range taskOrder {
+			if t == task {
+				known = true
+				break
+			}
+		}
+		if enabled && !known {
+			unknown = append(unknown, task)
+		}
+	}
+	sort.Strings(unknown)
+	return append(tasks, unknown...)
+}
+
 func (c *Collect) Run() error {
+	return c.RunContext(context.Background())
+}
+
+// RunContext do all enabled tasks, at most conf.Workers tasks at the same time
+// a failed task does not stop others, cancel ctx to stop the tasks not finished
+func (c *Collect) RunContext(ctx context.Context) error {
 	// load all src files, record fd
 	if err := c.loadSrcFiles(); err != nil {
 		return err
@@ -104,61 +228,138 @@ func (c *Collect) Run() error {
 	if err := c.createDstFile("项目立项及实际费用明细.xlsx"); err != nil {
 		return err
 	}
+	writer := c.dstWriters["项目立项及实际费用明细.xlsx"]
+	defer writer.Close()
 
 	// do task concurrently or sequentially
-	var runErr []error
-	errChan := make(chan error, len(c.conf.TaskMap))
-	wg := &sync.WaitGroup{}
-	for task, enabled := range c.conf.TaskMap {
-		if !enabled {
-			continue
+	tasks := c.tasks()
+	c.results = make([]TaskResult, len(tasks))
+	// each task has its own readers, so concurrent tasks only compete for dst writer
+	workers := 1
+	if c.conf.Concurrent {
+		workers = c.conf.Workers
+		if workers <= 0 {
+			workers = len(tasks)
 		}
-		if c.conf.Concurrent {
-			wg.Add(1)
-			go c.doTask(task, wg, errChan)
-		} else {
-			c.doTask(task, wg, errChan)
+	}
+	// create dst sheets in order of tasks, not in order of finishing
+	if err := writer.Do(func(f *excelize.File) error {
+		return c.createDstSheets(f, tasks)
+	}); err != nil {
+		return err
+	}
+	// each task only updates its own stats, so the map is not changed while tasks run
+	c.stats = make(map[string]*TaskStats, len(tasks))
+	for _, task := range tasks {
+		c.stats[task] = &TaskStats{}
+	}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
+	for i, task := range tasks {
+		i, task := i, task
+		g.Go(func() error {
+			start := time.Now()
+			err := c.doTask(gctx, task)
+			stats := c.stats[task]
+			stats.RowsSkipped = c.report.Count(task)
+			stats.RowsRead += stats.RowsSkipped
+			c.results[i] = TaskResult{Task: task, Err: err, Duration: time.Since(start), TaskStats: *stats}
+			return nil // not cancel other tasks, all errors are reported
+		})
+	}
+	_ = g.Wait()
+
+	var runErr RunError
+	for _, result := range c.results {
+		if result.Err != nil {
+			runErr = runErr.add(result.Task, result.Err)
 		}
+	}
 
-		wg.Add(1)
-		go func(wg *sync.WaitGroup) {
-			defer wg.Done()
-			if err := <-errChan; err != nil {
-				runErr = append(runErr, err)
+	// write report of skipped rows even if some task failed
+	if err := writer.Do(c.writeRollups); err != nil {
+		runErr = runErr.add("rollup", err)
+	}
+	if err := writer.Do(c.writePivots); err != nil {
+		runErr = runErr.add("pivot", err)
+	}
+	if err := writer.Do(c.writeCharts); err != nil {
+		runErr = runErr.add("chart", err)
+	}
+	if err := writer.Do(c.formatSheets); err != nil {
+		runErr = runErr.add("format", err)
+	}
+	if err := writer.Do(c.writeReport); err != nil {
+		runErr = runErr.add("report", err)
+	}
+	if err := writer.Do(c.writeOrgConflicts); err != nil {
+		runErr = runErr.add("org", err)
+	}
+	if err := writer.Do(c.manifest.write); err != nil {
+		runErr = runErr.add("manifest", err)
+	}
+	if err := writer.Do(func(f *excelize.File) error { return f.Save() }); err != nil {
+		runErr = runErr.add("save", err)
+	} else if c.conf.Ods {
+		if err := c.writeOds("项目立项及实际费用明细.xlsx"); err != nil {
+			runErr = runErr.add("ods", err)
+		}
+	}
+	return runErr.sorted()
+}
+
+func (c *Collect) createDstSheets(f *excelize.File, tasks []string) error {
+	for _, task := range tasks {
+		schema, ok := c.conf.Schema[task]
+		if !ok {
+			continue
+		}
+		if f.GetSheetIndex(schema.Target) == -1 {
+			index := f.NewSheet(schema.Target)
+			c.newSheets[schema.Target] = true
+			f.SetActiveSheet(index)
+			if err := f.SetSheetVisible("Sheet1", false); err != nil {
+				return err
 			}
-		}(wg)
+		}
+		if err := writeDstHeader(f, schema); err != nil {
+			return &TaskError{Task: task, File: "项目立项及实际费用明细.xlsx", Sheet: schema.Target, Err: err}
+		}
 	}
+	return nil
+}
 
-	wg.Wait()
-	if runErr != nil {
-		return runErr[0] // only return the first error
-	} else {
-		return nil
+// Results return result of each task in the last run, in scheduling order
+func (c *Collect) Results() []TaskResult {
+	return c.results
+}
+
+func (c *Collect) writeReport(f *excelize.File) error {
+	if err := c.report.WriteSheet(f, "校验报告"); err != nil {
+		return err
 	}
+	return c.report.WriteCSV(c.dstDir + "/校验报告.csv")
 }
 
-func (c *Collect) doTask(task string, wg *sync.WaitGroup, errChan chan error) {
+func (c *Collect) doTask(ctx context.Context, task string) error {
+	// the run is canceled before the task starts
+	if err := ctx.Err(); err != nil {
+		fmt.Println("task[", task, "]: canceled")
+		return RunError(nil).add(task, err)
+	}
 	var err error
 	switch task {
-	case "content":
-		err = c.CollectForContent()
-	case "campaign":
-		err = c.CollectForAll("活动")
-	case "cps":
-		err = c.CollectForAll("CPS分发")
-	case "newgame":
-		err = c.CollectForAll("新游预约")
+	case "content", "mcn":
+		err = c.CollectForDetail(ctx, task)
+	case "campaign", "cps", "newgame":
+		err = c.CollectForAll(ctx, task)
 	default:
 		err = errors.New("unsupported task")
 	}
 	if err != nil {
 		fmt.Println("task[", task, "]: failed")
-		errChan <- err
-	} else {
-		fmt.Println("task[", task, "]: successful")
-		errChan <- nil
-	}
-	if c.conf.Concurrent {
-		wg.Done()
+		return RunError(nil).add(task, err)
 	}
+	fmt.Println("task[", task, "]: successful")
+	return nil
 }
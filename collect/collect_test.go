@@ -0,0 +1,389 @@
+package collect
+
+import (
+	"context"
+	"errors"
+	"excel/config"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tasks of src files written by writeSrcFiles, by dst sheet
+var testTargets = map[string]string{"campaign": "活动", "cps": "CPS分发", "newgame": "新游预约"}
+
+// writeSrcFiles write src files of two months, each has sheets of campaign, cps and newgame
+func writeSrcFiles(t *testing.T, dir string) {
+	t.Helper()
+	for _, month := range []int{9, 10} {
+		writeSrcFile(t, dir, month)
+	}
+}
+
+// writeSrcFile write src file of month with sheets of campaign, cps and newgame, 3 rows in each
+func writeSrcFile(t *testing.T, dir string, month int) {
+	t.Helper()
+	f := excelize.NewFile()
+	for task, sheet := range testTargets {
+		key := "项目名称"
+		if task == "campaign" {
+			key = "入库活动名"
+		}
+		f.NewSheet(sheet)
+		rows := [][]interface{}{
+			{key, "运营部门", "游戏产品", "开始日期", "结束日期", "金额"},
+		}
+		for i := 1; i <= 3; i++ {
+			rows = append(rows, []interface{}{
+				fmt.Sprintf("%s%d-%d", sheet, month, i), "部门" + fmt.Sprint(i), "游戏" + fmt.Sprint(i),
+				fmt.Sprintf("2021/%d/%d", month, i), fmt.Sprintf("2021/%d/%d", month, i+10), fmt.Sprint(i * 100),
+			})
+		}
+		for i, row := range rows {
+			axis, _ := excelize.CoordinatesToCellName(1, i+1)
+			if err := f.SetSheetRow(sheet, axis, &row); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	f.DeleteSheet("Sheet1")
+	if err := f.SaveAs(filepath.Join(dir, fmt.Sprintf("2021年%d月费用.xlsx", month))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testConfig load config of tasks with src and dst in a temp dir
+func testConfig(t *testing.T, tasks ...string) *config.Config {
+	t.Helper()
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeSrcFiles(t, src)
+	ini := fmt.Sprintf("[task]\ncontent=0\nmcn=0\ncampaign=0\ncps=0\nnewgame=0\n[directory]\nsrc=%q\ndst=%q\n", src, dst)
+	path := filepath.Join(dir, "config.ini")
+	if err := os.WriteFile(path, []byte(ini), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := config.InitConf(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range tasks {
+		conf.TaskMap[task] = true
+	}
+	return conf
+}
+
+// dstRows read rows of dst sheets of tasks, sheets not created are left out
+func dstRows(t *testing.T, conf *config.Config) map[string][][]string {
+	t.Helper()
+	f, err := excelize.OpenFile(filepath.Join(conf.DstPath, "项目立项及实际费用明细.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := make(map[string][][]string)
+	for _, sheet := range testTargets {
+		if f.GetSheetIndex(sheet) == -1 {
+			continue
+		}
+		if rows[sheet], err = f.GetRows(sheet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rows
+}
+
+func TestRunContext(t *testing.T) {
+	var want map[string][][]string
+	for _, concurrent := range []bool{false, true} {
+		for _, workers := range []int{0, 1, 3} {
+			t.Run(fmt.Sprintf("concurrent=%v,workers=%d", concurrent, workers), func(t *testing.T) {
+				conf := testConfig(t, "campaign", "cps", "newgame")
+				conf.Concurrent, conf.Workers = concurrent, workers
+				c := NewCollect(conf)
+				if err := c.RunContext(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+
+				tasks := make([]string, 0)
+				for _, result := range c.Results() {
+					tasks = append(tasks, result.Task)
+					if result.Err != nil || result.RowsRead != 6 || result.RowsWritten != 6 || len(result.Files) != 2 {
+						t.Errorf("result of %s = %+v, want 6 rows of 2 files", result.Task, result)
+					}
+				}
+				if want := []string{"campaign", "cps", "newgame"}; !reflect.DeepEqual(tasks, want) {
+					t.Errorf("tasks of results = %v, want %v", tasks, want)
+				}
+
+				// rows are the same whatever tasks run at the same time, src files are in order of names
+				rows := dstRows(t, conf)
+				if len(rows["活动"]) != 7 || rows["活动"][1][0] != "活动10-1" || rows["活动"][6][0] != "活动9-3" {
+					t.Errorf("rows of 活动 = %q, want header and 6 rows in order of files", rows["活动"])
+				}
+				if want == nil {
+					want = rows
+				} else if !reflect.DeepEqual(rows, want) {
+					t.Errorf("dst rows = %q, want %q", rows, want)
+				}
+			})
+		}
+	}
+}
+
+func TestRunContextTaskError(t *testing.T) {
+	for _, concurrent := range []bool{false, true} {
+		t.Run(fmt.Sprintf("concurrent=%v", concurrent), func(t *testing.T) {
+			conf := testConfig(t, "cps", "unknown")
+			conf.Concurrent = concurrent
+			c := NewCollect(conf)
+			err := c.RunContext(context.Background())
+
+			var runErr RunError
+			if !errors.As(err, &runErr) || len(runErr) != 1 || runErr[0].Task != "unknown" {
+				t.Fatalf("err = %v, want error of task unknown only", err)
+			}
+			results := c.Results()
+			if len(results) != 2 || results[0].Task != "cps" || results[1].Task != "unknown" {
+				t.Fatalf("results = %+v, want cps and unknown", results)
+			}
+			if results[0].Err != nil || results[0].RowsWritten != 6 {
+				t.Errorf("result of cps = %+v, want 6 rows written", results[0])
+			}
+			if results[1].Err == nil {
+				t.Error("error of task unknown is not collected")
+			}
+			// dst file is saved with rows of other tasks
+			if rows := dstRows(t, conf); len(rows["CPS分发"]) != 7 {
+				t.Errorf("rows of CPS分发 = %q, want header and 6 rows", rows["CPS分发"])
+			}
+		})
+	}
+}
+
+func TestRunContextCancel(t *testing.T) {
+	for _, concurrent := range []bool{false, true} {
+		t.Run(fmt.Sprintf("concurrent=%v", concurrent), func(t *testing.T) {
+			conf := testConfig(t, "campaign", "cps", "newgame")
+			conf.Concurrent = concurrent
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			c := NewCollect(conf)
+			err := c.RunContext(ctx)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("err = %v, want context canceled", err)
+			}
+			for _, result := range c.Results() {
+				if !errors.Is(result.Err, context.Canceled) || result.RowsWritten != 0 {
+					t.Errorf("result of %s = %+v, want canceled without rows written", result.Task, result)
+				}
+			}
+			// dst file is still saved with sheets of tasks, header is union of src headers so it is blank too
+			rows := dstRows(t, conf)
+			if len(rows) != len(testTargets) {
+				t.Errorf("dst sheets = %d, want %d", len(rows), len(testTargets))
+			}
+			for sheet, rows := range rows {
+				if len(rows) != 0 {
+					t.Errorf("rows of %s = %q, want blank", sheet, rows)
+				}
+			}
+		})
+	}
+}
+
+// TestRunContextDetail check src cols of content and mcn are found by header name in any order
+func TestRunContextDetail(t *testing.T) {
+	conf := testConfig(t, "content", "mcn")
+	f := excelize.NewFile()
+	sheets := map[string][][]interface{}{
+		"内容创作者": {
+			{"备注", "税前金额（自动计算）", "昵称", "UID", "游戏产品", "运营部门", "动态类型", "出资方", "阅读量"},
+			{"", "1,000", "甲", "u1", "游戏A", "部门A", "视频", "自投", "1.2万"},
+			{"x", "500", "", "u2", "游戏A", "部门A", "图文"}, // no nick name
+			{"y", "200", "乙", "u2", "游戏B", "部门B"},
+			{"合计"},
+			{"", "100", "丙", "u3", "游戏B", "部门B"},
+		},
+		"MCN": {
+			{"备注", "MCN机构", "UID", "昵称", "运营部门", "游戏产品", "出资方", "税前金额（自动计算）"},
+			{"", "机构M", "u1", "甲", "部门A", "游戏A", "自投", "800"},
+			{"", "", "u2", "乙", "部门B", "游戏B", "", "300"}, // no agency
+		},
+	}
+	for sheet, rows := range sheets {
+		f.NewSheet(sheet)
+		for i, row := range rows {
+			axis, _ := excelize.CoordinatesToCellName(1, i+1)
+			if err := f.SetSheetRow(sheet, axis, &row); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := f.SaveAs(filepath.Join(conf.SrcPath, "2021年11月明细.xlsx")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(conf.SrcPath, "org.csv"), []byte("kol_type,uid,add_date\nMCN甲,u1,\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCollect(conf)
+	if err := c.RunContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	dst, err := excelize.OpenFile(filepath.Join(conf.DstPath, "项目立项及实际费用明细.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][][]string{
+		"大神内域作者费用明细": {
+			{"月份", "机构", "部门", "游戏", "UID", "昵称", "视频费用", "图文费用", "不能区分", "类别", "出资方", "阅读量"},
+			{"2021年11月", "MCN甲", "部门A", "游戏A", "u1", "甲", "1000.00", "", "", "内容创作者", "自投", "12000"},
+			{"2021年11月", "其他_付费kol", "部门B", "游戏B", "u2", "乙", "", "", "200.00", "内容创作者"},
+		},
+		"MCN机构费用明细": {
+			{"月份", "机构", "MCN机构", "部门", "游戏", "UID", "昵称", "金额", "出资方"},
+			{"2021年11月", "MCN甲", "机构M", "部门A", "游戏A", "u1", "甲", "800.00", "自投"},
+		},
+	}
+	for sheet, want := range want {
+		rows, err := dst.GetRows(sheet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(rows, want) {
+			t.Errorf("rows of %s = %q, want %q", sheet, rows, want)
+		}
+	}
+
+	reasons := make([]string, 0)
+	for _, entry := range c.report.Entries() {
+		if entry.File == "2021年11月明细.xlsx" {
+			reasons = append(reasons, fmt.Sprintf("%s %d %s", entry.Task, entry.Row, entry.Reason))
+		}
+	}
+	wantReasons := []string{
+		"content 3 " + reasonNotEnough, "content 5 " + reasonEnd, "content 6 " + reasonAfterEnd,
+		"mcn 3 " + reasonNotEnough,
+	}
+	if !reflect.DeepEqual(reasons, wantReasons) {
+		t.Errorf("report = %q, want %q", reasons, wantReasons)
+	}
+}
+
+// tableName get name of table part
+func tableName(f *excelize.File, part string) string {
+	content, ok := f.Pkg.Load(part)
+	if !ok {
+		return ""
+	}
+	attr := string(nameReg.Find(rootTag(content.([]byte))))
+	return strings.TrimSuffix(strings.TrimPrefix(attr, ` name="`), `"`)
+}
+
+// TestRunContextAppend check a table of user and formulas using it are kept by runs in append mode,
+// while tables, pivot tables and charts of sheets updated in every run are rewritten
+func TestRunContextAppend(t *testing.T) {
+	conf := testConfig(t, "campaign", "cps", "newgame")
+	conf.Append = true
+	// name of pivot sheet does not contain name of a task sheet, such as "活动", or rows are written into it
+	conf.Pivots = append(conf.Pivots, &config.Pivot{Sheet: "费用透视", Source: "活动", Rows: []string{"运营部门"},
+		Columns: []string{"游戏产品"}, Data: []string{"金额"}, Subtotal: "Sum"})
+	path := filepath.Join(conf.DstPath, "项目立项及实际费用明细.xlsx")
+	run := func() {
+		t.Helper()
+		if err := NewCollect(conf).RunContext(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run()
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.NewSheet("财务手工")
+	for i, row := range [][]interface{}{{"项目", "金额"}, {"a", 100}, {"b", 250}} {
+		if err := f.SetSheetRow("财务手工", fmt.Sprintf("A%d", i+1), &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.AddTable("财务手工", "A1", "B3", `{"table_style":"TableStyleMedium2"}`); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := sheetTables(f, "财务手工")
+	if err != nil || len(tables) != 1 {
+		t.Fatalf("tables = %q, %v, want the table added", tables, err)
+	}
+	name := tableName(f, tables[0])
+	formula := "SUM(" + name + "[金额])"
+	if err := f.SetCellValue("财务手工", "D1", 350); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetCellFormula("财务手工", "D1", formula); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second run collects a new src file, the third run collects nothing
+	for i, month := range []int{11, 0} {
+		if month != 0 {
+			writeSrcFile(t, conf.SrcPath, month)
+		}
+		run()
+
+		f, err := excelize.OpenFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tables, err := sheetTables(f, "财务手工")
+		if err != nil || len(tables) != 1 {
+			t.Fatalf("run %d: tables = %q, %v, want the table of user", i+2, tables, err)
+		}
+		content, _ := f.Pkg.Load(tables[0])
+		if tag := string(rootTag(content.([]byte))); !strings.Contains(tag, ` name="`+name+`"`) ||
+			!strings.Contains(tag, ` ref="A1:B3"`) {
+			t.Errorf("run %d: table = %s, want %s of A1:B3", i+2, tag, name)
+		}
+		if got, err := f.GetCellFormula("财务手工", "D1"); err != nil || got != formula {
+			t.Errorf("run %d: formula = %q, %v, want %q", i+2, got, err, formula)
+		}
+		if got, err := f.GetCellValue("财务手工", "D1"); err != nil || got != "350" {
+			t.Errorf("run %d: value = %q, %v, want 350", i+2, got, err)
+		}
+
+		// names of tables are unique, and sheets updated in every run have one table, pivot table or chart
+		names := make(map[string]string)
+		for _, sheet := range f.GetSheetList() {
+			tables, err := sheetTables(f, sheet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, table := range tables {
+				name := tableName(f, table)
+				if names[name] != "" {
+					t.Errorf("run %d: table %s of %s is also on %s", i+2, name, sheet, names[name])
+				}
+				names[name] = sheet
+			}
+		}
+		for sheet, relType := range map[string]string{
+			rollupSheet: relTypeTable, "费用透视": relTypePivotTable, "月度费用趋势": relTypeDrawing,
+		} {
+			if rels, err := sheetTargets(f, sheet, relType); err != nil || len(rels) != 1 {
+				t.Errorf("run %d: parts of %s = %v, %v, want one", i+2, sheet, rels, err)
+			}
+		}
+		if rows, err := f.GetRows("活动"); err != nil || len(rows) != 10 {
+			t.Errorf("run %d: rows of 活动 = %d, %v, want header and 9 rows", i+2, len(rows), err)
+		}
+	}
+}
@@ -4,6 +4,7 @@
 package collect
 
 import (
+	"context"
 	"github.com/xuri/excelize/v2"
 	"strconv"
 	"strings"
@@ -11,19 +12,12 @@ import (
 )
 
 const (
-	// indexs in Sheet struct
-	startDate = 0
-	endDate   = 1
-	cmIdEnd   = 2 // increase this number when add new index
+	// fields in schema
+	keyCol    = "key"       // 入库活动名列，或项目名称列
+	startDate = "startDate" // 开始日期
+	endDate   = "endDate"   // 结束日期
 )
 
-var commonMap = map[string]int{
-	// key col start from zero for each common sheet
-	"活动":    4, // 入库活动名列
-	"CPS分发": 5, // 项目名称列
-	"新游预约":  4, // 项目名称列
-}
-
 func timeToExcelTime(t time.Time) (float64, error) {
 	const (
 		dayNanoseconds = 24 * time.Hour
@@ -55,20 +49,8 @@ func timeToExcelTime(t time.Time) (float64, error) {
 	return result, nil
 }
 
-func convertIfDate(f *excelize.File, sheetName string, col int, row int, data string) (string, bool) {
-	coords, _ := excelize.CoordinatesToCellName(col, row)
-	style, err := f.GetCellStyle(sheetName, coords)
-	if style == 0 {
-		return "", false
-	}
-	styleSheet := f.Styles
-	if style >= len(styleSheet.CellXfs.Xf) {
-		return "", false
-	}
-	var numFmtID int
-	if styleSheet.CellXfs.Xf[style].NumFmtID != nil {
-		numFmtID = *styleSheet.CellXfs.Xf[style].NumFmtID
-	}
+func convertIfDate(f workbook, sheetName string, col int, row int, data string) (string, bool) {
+	numFmtID := f.NumFmt(sheetName, col, row)
 	var timeFormat string
 	switch numFmtID {
 	case 14:
@@ -141,93 +123,102 @@ func parseDate(dateRaw string) (string, error) {
 }
 
 func (s *Sheet) ReadSheetAll() error {
-	sheetList := s.file.GetSheetList()
+	sheetList := s.book.GetSheetList()
+	var merged *headerUnion // header of all matched sheets, nil if only one
 	for _, sheetName := range sheetList {
-		// skip hidden sheet
-		if !s.file.GetSheetVisible(sheetName) {
-			continue
-		}
-		if strings.Contains(sheetName, s.name) {
-			startFound, err := s.file.SearchSheet(sheetName, s.start)
+		if s.matchSheet(sheetName) {
+			// skip hidden sheet
+			if !s.book.GetSheetVisible(sheetName) {
+				s.skipRow(sheetName, 0, reasonHidden, nil)
+				continue
+			}
+			startFound, err := s.book.SearchSheet(sheetName, s.start)
 			if err != nil {
-				return err
+				return s.taskErr(sheetName, err)
 			} else if startFound == nil {
+				s.skipRow(sheetName, 0, reasonNoAnchor, nil)
 				continue
 			}
 			s.col, s.row, _ = excelize.CellNameToCoordinates(startFound[0])
 
 			// traverse this sheet and get data from start coordinate
 			curRow := 0
-			rowsIt, err := s.file.Rows(sheetName)
+			ended := false  // rows after data end are only recorded in report
+			var remap []int // dst col of each col, nil for the first sheet of file
+			var header []string
+			rowsIt, err := s.book.Rows(sheetName)
 			if err != nil {
-				return err
+				return s.taskErr(sheetName, err)
 			}
 			for rowsIt.Next() {
 				curRow++
 				colsData, err := rowsIt.Columns()
 				if err != nil {
-					return err
+					return s.taskErr(sheetName, err)
 				} else if curRow < s.row {
 					continue
 				} else if curRow == s.row {
-					for id, colData := range colsData {
-						if strings.Contains(colData, "开始日期") {
-							s.indexs[startDate] = id
-						} else if strings.Contains(colData, "结束日期") {
-							s.indexs[endDate] = id
-						}
+					if err := s.findCols(sheetName, colsData); err != nil {
+						return s.taskErr(sheetName, err)
 					}
-				} else if colsData == nil {
-					break // absolutely data end
-				} else if len(colsData) > commonMap[s.name] {
-					needBreak := true // maybe data end
-					for i := 0; i <= commonMap[s.name]; i++ {
-						if len(colsData[i]) != 0 {
-							needBreak = false
-							break
+					header = colsData
+					if s.header == nil {
+						s.header = colsData
+					} else {
+						// another sheet of the same file, its rows are aligned to header of the first one by name
+						if merged == nil {
+							merged = newHeaderUnion(s.header)
 						}
+						remap, _ = merged.align("", header)
+						s.header = merged.header
 					}
-					if needBreak {
-						// (len(colsData[0]) == 0) && ... && (len(colsData[n]) == 0) is true
-						break
-					}
+					continue
+				} else if ended {
+					s.skipRow(sheetName, curRow, reasonAfterEnd, colsData)
+					continue
+				} else if colsData == nil {
+					ended = true // absolutely data end
+					continue
+				}
+				if blank, partial := s.checkRequired(colsData); blank {
+					ended = true // maybe data end
+					s.skipRow(sheetName, curRow, reasonEnd, colsData)
+					continue
+				} else if s.schema.Skip != "" && strings.Contains(s.colValue(colsData, keyCol), s.schema.Skip) {
+					s.skipRow(sheetName, curRow, reasonSkip, colsData)
+					continue // skip this row
+				} else if partial {
+					s.skipRow(sheetName, curRow, reasonNotEnough, colsData)
+					continue // data not enough
+				}
 
-					needContinue := false // true for data not enough
-					if strings.Contains(colsData[commonMap[s.name]], "辅助") {
-						continue // skip this row
-					}
-					for i := 0; i <= commonMap[s.name]; i++ {
-						if len(colsData[i]) == 0 {
-							needContinue = true
-							break
+				// deal with date formatted col
+				for _, field := range []string{startDate, endDate} {
+					if col, ok := s.cols[field]; ok && col < len(colsData) {
+						if dateExcel, isCv := convertIfDate(s.book, sheetName, col+1, curRow, colsData[col]); isCv {
+							colsData[col] = dateExcel
 						}
 					}
-					if needContinue {
-						// (len(colsData[0]) == 0) || ... || (len(colsData[n]) == 0) is true
-						continue
-					}
-				} else if len(colsData) <= commonMap[s.name] {
-					break // maybe data end
 				}
-
-				if curRow != s.row {
-					// deal with date formatted col
-					if dateExcel, isCv := convertIfDate(s.file, sheetName, s.indexs[startDate]+1, curRow, colsData[s.indexs[startDate]]); isCv {
-						colsData[s.indexs[startDate]] = dateExcel
-					}
-					if dateExcel, isCv := convertIfDate(s.file, sheetName, s.indexs[endDate]+1, curRow, colsData[s.indexs[endDate]]); isCv {
-						colsData[s.indexs[endDate]] = dateExcel
+				s.checkValues(sheetName, curRow, colsData)
+				if remap != nil {
+					if len(colsData) > len(remap) {
+						remap, _ = merged.align("", padHeader(header, len(colsData)))
+						s.header = merged.header
 					}
+					colsData = remapRow(colsData, remap, len(merged.header))
 				}
 				s.data = append(s.data, colsData)
 			}
 		}
 	}
+	if merged != nil {
+		s.mergeCols()
+	}
 	return nil
 }
 
 func (s *Sheet) WriteSheetAll(from *Sheet) error {
-	s.fileMutex.Lock()
 	sheetList := s.file.GetSheetList()
 	foundSheet := false
 	for _, sheetName := range sheetList {
@@ -243,22 +234,51 @@ func (s *Sheet) WriteSheetAll(from *Sheet) error {
 		if err := s.file.SetSheetVisible("Sheet1", false); err != nil {
 			return err
 		}
-		if err := s.file.Save(); err != nil {
-			return err
-		}
 	}
-	s.fileMutex.Unlock()
 	var dstAxis string
 	var err error
 	dateStyle, _ := s.file.NewStyle(`{"number_format": 14}`)
-	for row, colsData := range from.data {
-		if row == 0 && s.row != 1 {
-			continue
+	isDate := func(col int) bool {
+		for _, field := range []string{startDate, endDate} {
+			if dateCol, ok := from.cols[field]; ok && dateCol == col {
+				return true
+			}
+		}
+		return false
+	}
+	colType := func(col int) string {
+		for field, typedCol := range from.cols {
+			if typedCol == col {
+				return from.fieldType(field)
+			}
 		}
+		return ""
+	}
+	if from.header == nil {
+		return nil // no header, no data
+	}
+
+	// dst header is union of src headers, names first seen are appended to it
+	width := len(from.header)
+	for _, colsData := range from.data {
+		width = max(width, len(colsData))
+	}
+	header := padHeader(from.header, width)
+	cols, added := s.union.align(from.fileName, header)
+	for _, col := range added {
+		dstAxis, _ = excelize.CoordinatesToCellName(cols[col]+1, 1)
+		if err = s.file.SetCellStr(s.name, dstAxis, strings.TrimSpace(header[col])); err != nil {
+			return err
+		}
+	}
+	if s.row == 1 {
+		s.row++
+	}
+	for _, colsData := range from.data {
 		for col, colData := range colsData {
-			dstAxis, _ = excelize.CoordinatesToCellName(col+1, s.row)
+			dstAxis, _ = excelize.CoordinatesToCellName(cols[col]+1, s.row)
 			// deal with date
-			if row != 0 && (col == from.indexs[startDate] || col == from.indexs[endDate]) {
+			if isDate(col) {
 				if err := s.file.SetCellStyle(s.name, dstAxis, dstAxis, dateStyle); err != nil {
 					return err
 				}
@@ -269,7 +289,25 @@ func (s *Sheet) WriteSheetAll(from *Sheet) error {
 				}
 			}
 
-			err = s.file.SetCellValue(s.name, dstAxis, colData)
+			// deal with number, value can not be parsed is kept as text
+			var value interface{} = colData
+			if serial, err := strconv.Atoi(colData); err == nil && isDate(col) {
+				value = serial // date serial as number, so it is shown as date and can be compared
+			}
+			if typ := colType(col); numFmt(typ) != 0 && colData != "" {
+				if number, err := parseValue(typ, colData); err == nil {
+					style, err := s.numStyle(typ)
+					if err != nil {
+						return err
+					}
+					if err := s.file.SetCellStyle(s.name, dstAxis, dstAxis, style); err != nil {
+						return err
+					}
+					value = number
+				}
+			}
+
+			err = s.file.SetCellValue(s.name, dstAxis, value)
 			if err != nil {
 				return err
 			}
@@ -278,41 +316,51 @@ func (s *Sheet) WriteSheetAll(from *Sheet) error {
 		s.row++
 	}
 
-	if err = s.file.Save(); err != nil {
-		return err
-	}
-
 	return nil
 }
 
-// CollectForAll collect for 活动，CPS分发，新游预约
-func (c *Collect) CollectForAll(keyword string) error {
-	sheets := make([]Sheet, 0)
-	for _, f := range c.srcFiles {
-		sheets = append(sheets, Sheet{
-			name:   keyword,
-			start:  "运营部门",
-			file:   f,
-			indexs: make([]int, cmIdEnd),
-		})
+// CollectForAll collect for 活动，CPS分发，新游预约, task is one of "campaign", "cps" and "newgame"
+func (c *Collect) CollectForAll(ctx context.Context, task string) error {
+	schema := c.conf.Schema[task]
+	sheets := make([]*Sheet, 0)
+	fnames := c.srcNamesOf(task)
+	for _, fname := range fnames {
+		f := c.srcFiles[fname]
+		for _, keyword := range schema.Sheets {
+			sheets = append(sheets, &Sheet{
+				name:      keyword,
+				start:     schema.Anchor,
+				book:      f,
+				fileName:  fname,
+				fileMutex: c.srcFilesMutex[fname],
+				schema:    schema,
+				task:      task,
+				report:    c.report,
+			})
+		}
 	}
 
-	targetSheet := &Sheet{
-		name:      keyword,
-		row:       1,
-		col:       1,
-		file:      c.dstFiles["项目立项及实际费用明细.xlsx"],
-		fileMutex: c.dstFilesMutex["项目立项及实际费用明细.xlsx"],
+	lastRow, err := c.dstLastRow("项目立项及实际费用明细.xlsx", schema.Target)
+	if err != nil {
+		return err
 	}
-
-	for _, sheet := range sheets {
-		if err := sheet.ReadSheetAll(); err != nil {
-			return err
-		}
-		if err := targetSheet.WriteSheetAll(&sheet); err != nil {
-			return err
-		}
+	dstHeader, err := c.dstHeader("项目立项及实际费用明细.xlsx", schema.Target)
+	if err != nil {
+		return err
+	}
+	targetSheet := &Sheet{
+		name:     schema.Target,
+		row:      lastRow + 1, // header is the first row, src cols are written under it by name
+		col:      1,
+		file:     c.dstFiles["项目立项及实际费用明细.xlsx"],
+		fileName: "项目立项及实际费用明细.xlsx",
+		task:     task,
+		union:    newHeaderUnion(dstHeader),
 	}
 
-	return nil
+	// go on with other src files when one fails, so all problems show in one run
+	err = c.pipeline(ctx, sheets, (*Sheet).ReadSheetAll, targetSheet, targetSheet.WriteSheetAll)
+	c.markCollected(task, fnames, err)
+	c.reportPartialCols(task, schema.Target, targetSheet.union)
+	return err
 }
@@ -0,0 +1,234 @@
+// code for collect detail sheets, whose dst cols are fixed by schema, into target sheet of task
+// now, detail sheets include content("内容"), namely "内容创作者，内容采购", into "大神内域作者费用明细",
+// and mcn("MCN") into "MCN机构费用明细"
+// parse period, namely year and month("月份"), from file name, see period.go
+// parse organization("机构") by uid from csv files, see org.go
+
+package collect
+
+import (
+	"context"
+	"github.com/xuri/excelize/v2"
+	"strings"
+)
+
+const (
+	// fields in schema for src
+	department = "department" // 运营部门
+	game       = "game"       // 游戏产品
+	sponsor    = "sponsor"    // 出资方
+	uid        = "uid"        // UID
+	nickName   = "nickName"   // 昵称
+	dynType    = "dynType"    // 动态类型
+	readCnt    = "readCnt"    // 阅读量
+	money      = "money"      // 金额（橙列）
+	agency     = "agency"     // MCN机构
+
+	// fields in schema only for dst, computed by collector, written if the schema has dst col of them
+	monthD      = "month"      // 月份
+	orgD        = "org"        // 机构
+	videoMoneyD = "videoMoney" // 视频费用
+	textMoneyD  = "textMoney"  // 图文费用
+	unclsMoneyD = "unclsMoney" // 不能区分（费用）
+	typeD       = "type"       // 类别
+)
+
+// ReadSheetDetail read rows of src sheets matched by schema, rows with required values empty are skipped
+func (s *Sheet) ReadSheetDetail() error {
+	sheetList := s.book.GetSheetList()
+	for _, sheetName := range sheetList {
+		if s.matchSheet(sheetName) {
+			// skip hidden sheet
+			if !s.book.GetSheetVisible(sheetName) {
+				s.skipRow(sheetName, 0, reasonHidden, nil)
+				continue
+			}
+			startFound, err := s.book.SearchSheet(sheetName, s.start)
+			if err != nil {
+				return s.taskErr(sheetName, err)
+			} else if startFound == nil {
+				s.skipRow(sheetName, 0, reasonNoAnchor, nil)
+				continue
+			}
+			s.col, s.row, _ = excelize.CellNameToCoordinates(startFound[0])
+
+			// traverse this sheet and get data from start coordinate
+			curRow := 0
+			ended := false // rows after data end are only recorded in report
+			rowsIt, err := s.book.Rows(sheetName)
+			if err != nil {
+				return s.taskErr(sheetName, err)
+			}
+			for rowsIt.Next() {
+				curRow++
+				colsData, err := rowsIt.Columns()
+				if err != nil {
+					return s.taskErr(sheetName, err)
+				} else if curRow < s.row {
+					continue
+				} else if curRow == s.row {
+					if err := s.findCols(sheetName, colsData); err != nil {
+						return s.taskErr(sheetName, err)
+					}
+
+					continue
+				} else if ended {
+					s.skipRow(sheetName, curRow, reasonAfterEnd, colsData)
+					continue
+				} else if colsData == nil {
+					ended = true // absolutely data end
+					continue
+				}
+				if blank, partial := s.checkRequired(colsData); blank {
+					ended = true // maybe data end
+					s.skipRow(sheetName, curRow, reasonEnd, colsData)
+					continue
+				} else if partial {
+					s.skipRow(sheetName, curRow, reasonNotEnough, colsData)
+					continue // data not enough
+				}
+				s.checkValues(sheetName, curRow, colsData)
+				s.data = append(s.data, colsData)
+			}
+		}
+	}
+
+	return nil
+}
+
+// WriteSheetDetail write rows of from into dst cols of schema, with month, org and money by dynamic type
+func (s *Sheet) WriteSheetDetail(from *Sheet) error {
+	sheetList := s.file.GetSheetList()
+	foundSheet := false
+	for _, sheetName := range sheetList {
+		if strings.Contains(sheetName, s.name) {
+			s.name = sheetName
+			foundSheet = true
+		}
+	}
+	if !foundSheet {
+		// create new sheet
+		index := s.file.NewSheet(s.name)
+		s.file.SetActiveSheet(index)
+		if err := s.file.SetSheetVisible("Sheet1", false); err != nil {
+			return err
+		}
+	}
+	var err error
+	exp := from.period.numFmt()
+	monthStyle, err := s.file.NewStyle(&excelize.Style{CustomNumFmt: &exp})
+	if err != nil {
+		return err
+	}
+	for _, colsData := range from.data {
+		s.row++
+		for _, column := range from.schema.Columns {
+			if colData := from.colValue(colsData, column.Field); colData != "" {
+				if err = s.setValue(column.Field, column.Type, colData); err != nil {
+					return err
+				}
+			}
+		}
+
+		// deal with month
+		if err = s.setCell(monthD, from.period.value()); err != nil {
+			return err
+		}
+		if err = s.setCellStyle(monthD, monthStyle); err != nil {
+			return err
+		}
+
+		// deal with org
+		if org, exist := s.org.lookup(from.colValue(colsData, uid), from.period); exist {
+			err = s.setCell(orgD, org)
+		} else {
+			err = s.setCell(orgD, "其他_付费kol")
+		}
+		if err != nil {
+			return err
+		}
+
+		// deal with sum
+		moneyData, moneyType := from.colValue(colsData, money), from.fieldType(money)
+		if dynTypeData := from.colValue(colsData, dynType); dynTypeData == "" {
+			err = s.setValue(unclsMoneyD, moneyType, moneyData)
+		} else if strings.Contains(dynTypeData, "视频") {
+			err = s.setValue(videoMoneyD, moneyType, moneyData)
+		} else {
+			err = s.setValue(textMoneyD, moneyType, moneyData)
+		}
+		if err != nil {
+			return err
+		}
+
+		// deal with type
+		if err = s.setCell(typeD, from.name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CollectForDetail collect for 内容，MCN, task is one of "content" and "mcn"
+func (c *Collect) CollectForDetail(ctx context.Context, task string) error {
+	orgs, err := c.loadOrgs()
+	if err != nil {
+		return err
+	}
+
+	schema := c.conf.Schema[task]
+	dst, err := dstCols(schema)
+	if err != nil {
+		return err
+	}
+
+	sheets := make([]*Sheet, 0)
+	fnames := c.srcNamesOf(task)
+	collected := make([]string, 0, len(fnames))
+	var fileErr RunError
+	for _, fname := range fnames {
+		f := c.srcFiles[fname]
+		filePeriod, ok, err := c.srcPeriod(task, fname)
+		if err != nil {
+			fileErr = fileErr.add(task, err)
+			continue
+		} else if !ok {
+			continue
+		}
+		collected = append(collected, fname)
+		for _, keyword := range schema.Sheets {
+			sheets = append(sheets, &Sheet{
+				name:      keyword,
+				start:     schema.Anchor,
+				book:      f,
+				fileName:  fname,
+				fileMutex: c.srcFilesMutex[fname],
+				period:    filePeriod,
+				schema:    schema,
+				task:      task,
+				report:    c.report,
+			})
+		}
+	}
+
+	lastRow, err := c.dstLastRow("项目立项及实际费用明细.xlsx", schema.Target)
+	if err != nil {
+		return err
+	}
+	targetSheet := &Sheet{
+		name:     schema.Target,
+		row:      max(lastRow, 1), // rows are appended after the last one
+		col:      1,
+		file:     c.dstFiles["项目立项及实际费用明细.xlsx"],
+		fileName: "项目立项及实际费用明细.xlsx",
+		task:     task,
+		dst:      dst,
+		org:      orgs,
+	}
+
+	// go on with other src files when one fails, so all problems show in one run
+	err = c.pipeline(ctx, sheets, (*Sheet).ReadSheetDetail, targetSheet, targetSheet.WriteSheetDetail)
+	c.markCollected(task, collected, err)
+	return fileErr.join(task, err)
+}
@@ -0,0 +1,94 @@
+// code for decoding csv files exported on chinese systems, such as GBK by excel and UTF-16 by "unicode text",
+// content is transcoded to utf-8 before unmarshalled
+
+package collect
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/dimchansky/utfbom"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// encodings of csv files by name in config, utf-8 is not transcoded
+var csvEncodings = map[string]encoding.Encoding{
+	"utf-16le": unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	"utf-16be": unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+	"gbk":      simplifiedchinese.GBK,
+	"gb18030":  simplifiedchinese.GB18030,
+}
+
+// csvEncoding get encoding name of csv file by config, "auto" if not set
+func (c *Collect) csvEncoding(fname string) string {
+	for file, name := range c.conf.CsvEncodings {
+		if strings.EqualFold(file, fname) { // keys of config are lower case
+			return name
+		}
+	}
+	if c.conf.CsvEncoding == "" {
+		return "auto"
+	}
+	return c.conf.CsvEncoding
+}
+
+// decodeCSV read csv file in encoding name and return content in utf-8, BOM is removed
+func decodeCSV(r io.Reader, name string) ([]byte, error) {
+	r, bom := utfbom.Skip(r)
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if name == "auto" {
+		name = detectEncoding(content, bom)
+	}
+	if name == "utf-8" {
+		return content, nil
+	}
+	enc, ok := csvEncodings[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported encoding %s", name)
+	}
+	return enc.NewDecoder().Bytes(content)
+}
+
+// detectEncoding guess encoding of content by BOM first, then by bytes,
+// text mostly in ascii has a zero byte in each utf-16 char, and other text is gb18030 if it is not valid utf-8
+func detectEncoding(content []byte, bom utfbom.Encoding) string {
+	switch bom {
+	case utfbom.UTF8:
+		return "utf-8"
+	case utfbom.UTF16LittleEndian:
+		return "utf-16le"
+	case utfbom.UTF16BigEndian:
+		return "utf-16be"
+	}
+	sample := content
+	if len(sample) > 4096 {
+		sample = sample[:4096]
+	}
+	if len(sample) >= 2 && bytes.IndexByte(sample, 0) >= 0 {
+		var evenZeros, oddZeros int
+		for i, b := range sample {
+			if b != 0 {
+				continue
+			} else if i%2 == 0 {
+				evenZeros++
+			} else {
+				oddZeros++
+			}
+		}
+		if oddZeros > evenZeros {
+			return "utf-16le"
+		}
+		return "utf-16be"
+	}
+	if utf8.Valid(content) {
+		return "utf-8"
+	}
+	return "gb18030"
+}
@@ -0,0 +1,88 @@
+package collect
+
+import (
+	"bytes"
+	"excel/config"
+	"github.com/dimchansky/utfbom"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+	"testing"
+)
+
+func TestDecodeCSV(t *testing.T) {
+	const text = "kol_type,uid,add_date\nMCN甲,1,2021-09-01\n其他,2,\n"
+	encode := func(enc encoding.Encoding) []byte {
+		content, err := enc.NewEncoder().Bytes([]byte(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return content
+	}
+	tests := []struct {
+		name     string
+		content  []byte
+		encoding string // of config
+		err      bool
+	}{
+		{"utf-8", []byte(text), "auto", false},
+		{"utf-8 with BOM", append([]byte("\xef\xbb\xbf"), text...), "auto", false},
+		{"utf-16le with BOM", encode(unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)), "auto", false},
+		{"utf-16be with BOM", encode(unicode.UTF16(unicode.BigEndian, unicode.UseBOM)), "auto", false},
+		{"utf-16le", encode(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)), "auto", false},
+		{"utf-16be", encode(unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)), "auto", false},
+		{"gbk", encode(simplifiedchinese.GBK), "auto", false},
+		{"gb18030", encode(simplifiedchinese.GB18030), "auto", false},
+		{"gbk of config", encode(simplifiedchinese.GBK), "gbk", false},
+		{"utf-16le with BOM of config", encode(unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)), "utf-16le", false},
+		{"utf-8 with BOM of config", append([]byte("\xef\xbb\xbf"), text...), "utf-8", false},
+		{"unsupported", []byte(text), "big5", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCSV(bytes.NewReader(tt.content), tt.encoding)
+			if tt.err {
+				if err == nil {
+					t.Errorf("no error of encoding %s", tt.encoding)
+				}
+				return
+			}
+			if err != nil || string(got) != text {
+				t.Errorf("decodeCSV = %q, %v, want %q", got, err, text)
+			}
+		})
+	}
+}
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{"ascii", []byte("uid,org\n1,a\n"), "utf-8"},
+		{"utf-8", []byte("机构,金额\n"), "utf-8"},
+		{"gbk", []byte("\xbb\xfa\xb9\xb9,\xbd\xf0\xb6\xee\n"), "gb18030"}, // 机构,金额
+		{"utf-16le", []byte("u\x00i\x00d\x00"), "utf-16le"},
+		{"utf-16be", []byte("\x00u\x00i\x00d"), "utf-16be"},
+		{"utf-16le of cjk", []byte("\x3a\x67\x84\x67\x2c\x00"), "utf-16le"}, // 机构,
+		{"empty", nil, "utf-8"},
+	}
+	for _, tt := range tests {
+		if got := detectEncoding(tt.content, utfbom.Unknown); got != tt.want {
+			t.Errorf("detectEncoding of %s = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCSVEncoding(t *testing.T) {
+	c := NewCollect(&config.Config{CsvEncoding: "utf-8", CsvEncodings: map[string]string{"org.csv": "gbk"}})
+	for fname, want := range map[string]string{"org.csv": "gbk", "ORG.CSV": "gbk", "org2.csv": "utf-8"} {
+		if got := c.csvEncoding(fname); got != want {
+			t.Errorf("csvEncoding(%s) = %s, want %s", fname, got, want)
+		}
+	}
+	if got := NewCollect(&config.Config{}).csvEncoding("org.csv"); got != "auto" {
+		t.Errorf("csvEncoding without config = %s, want auto", got)
+	}
+}
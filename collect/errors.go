@@ -0,0 +1,135 @@
+// code for structured errors returned by collect
+
+package collect
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HeaderError is returned when header row of src sheet can not be resolved by schema
+type HeaderError struct {
+	File, Sheet string
+	Row         int                 // header row number start from one
+	Missing     []string            // header of required field not found
+	Duplicated  map[string][]string // header found more than once, col names found
+}
+
+func (e *HeaderError) Error() string {
+	problems := make([]string, 0, len(e.Missing)+len(e.Duplicated))
+	for _, header := range e.Missing {
+		problems = append(problems, "“"+header+"”列找不到")
+	}
+	headers := make([]string, 0, len(e.Duplicated))
+	for header := range e.Duplicated {
+		headers = append(headers, header)
+	}
+	sort.Strings(headers)
+	for _, header := range headers {
+		problems = append(problems, "多于1个“"+header+"”列("+strings.Join(e.Duplicated[header], ",")+")")
+	}
+	return fmt.Sprintf("第%d行表头：%s", e.Row, strings.Join(problems, "；"))
+}
+
+// LayoutError is returned when existing dst sheet is not in cols of schema, such as written by older versions
+// with other dst cols, rows appended to it would be under wrong titles
+type LayoutError struct {
+	Sheet    string
+	Mismatch []string // cells of the first row not matching titles of schema
+	Outside  []string // cols having data but no dst field in schema, for sheet without titles
+}
+
+func (e *LayoutError) Error() string {
+	problems := make([]string, 0, len(e.Mismatch)+1)
+	problems = append(problems, e.Mismatch...)
+	if len(e.Outside) != 0 {
+		problems = append(problems, "第1行没有表头且"+strings.Join(e.Outside, ",")+"列有数据")
+	}
+	return fmt.Sprintf("工作表%s的列与mapping.ini不一致：%s；请按现有的列修改mapping.ini的dst和title，或把该表改名后重新收集",
+		e.Sheet, strings.Join(problems, "；"))
+}
+
+// TaskError is error of one task, with src file and sheet where it occurs
+type TaskError struct {
+	Task  string
+	File  string // empty if error is not about one file
+	Sheet string // empty if error is not about one sheet
+	Err   error
+}
+
+func (e *TaskError) Error() string {
+	msg := "task[" + e.Task + "]"
+	if e.File != "" {
+		msg += " " + e.File
+	}
+	if e.Sheet != "" {
+		msg += " " + e.Sheet
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+// RunError is all errors of one run, sorted by task, file and sheet
+type RunError []*TaskError
+
+func (e RunError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, taskErr := range e {
+		msgs = append(msgs, taskErr.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e RunError) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, taskErr := range e {
+		errs = append(errs, taskErr)
+	}
+	return errs
+}
+
+// add append err of task, err is flattened if it is RunError or TaskError already
+func (e RunError) add(task string, err error) RunError {
+	var runErr RunError
+	var taskErr *TaskError
+	if errors.As(err, &runErr) {
+		return append(e, runErr...)
+	} else if errors.As(err, &taskErr) {
+		return append(e, taskErr)
+	}
+	return append(e, &TaskError{Task: task, Err: err})
+}
+
+// join add err of task if it is not nil, and return all as one error
+func (e RunError) join(task string, err error) error {
+	if err != nil {
+		e = e.add(task, err)
+	}
+	return e.sorted()
+}
+
+// sorted return nil if there is no error, so it can be returned as error safely
+func (e RunError) sorted() error {
+	if len(e) == 0 {
+		return nil
+	}
+	sort.SliceStable(e, func(i, j int) bool {
+		if e[i].Task != e[j].Task {
+			return e[i].Task < e[j].Task
+		} else if e[i].File != e[j].File {
+			return e[i].File < e[j].File
+		}
+		return e[i].Sheet < e[j].Sheet
+	})
+	return e
+}
+
+// taskErr wrap err occurs in sheet of src file
+func (s *Sheet) taskErr(sheetName string, err error) error {
+	return &TaskError{Task: s.task, File: s.fileName, Sheet: sheetName, Err: err}
+}
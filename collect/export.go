@@ -0,0 +1,174 @@
+// code for writing dst file in other formats, the xlsx is always written first
+
+package collect
+
+import (
+	"bytes"
+	"encoding/xml"
+	"excel/ods"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// numFmtLiteralReg match text quoted, escaped and in brackets of number format, such as "年", \- and [Red]
+var numFmtLiteralReg = regexp.MustCompile(`"[^"]*"|\\.|\[[^\]]*\]`)
+
+// rawCell is cell of worksheet as saved, excelize renders numbers by number format when read
+type rawCell struct {
+	Ref     string    `xml:"r,attr"`
+	Type    string    `xml:"t,attr"`
+	Value   string    `xml:"v"`
+	Formula *struct{} `xml:"f"`
+}
+
+// rawCells read cells of sheet having number value or formula
+func rawCells(f *excelize.File, sheet string) ([]rawCell, error) {
+	part, err := sheetPart(f, sheet)
+	if err != nil {
+		return nil, err
+	}
+	content, ok := f.Pkg.Load(part)
+	if part == "" || !ok {
+		return nil, nil
+	}
+	cells := make([]rawCell, 0)
+	d := xml.NewDecoder(bytes.NewReader(content.([]byte)))
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return cells, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if e, ok := token.(xml.StartElement); ok && e.Name.Local == "c" {
+			var cell rawCell
+			if err := d.DecodeElement(&cell, &e); err != nil {
+				return nil, err
+			}
+			if cell.Formula != nil || (cell.Type == "" || cell.Type == "n") && cell.Value != "" {
+				cells = append(cells, cell)
+			}
+		}
+	}
+}
+
+// dateType get ods type of date number format, false if not a date format,
+// custom format without day is shown as month, such as yyyy"年"m"月"
+func dateType(f *excelize.File, numFmtID int) (ods.CellType, bool) {
+	switch {
+	case numFmtID >= 14 && numFmtID <= 17 || numFmtID == 22:
+		return ods.Date, true
+	case f.Styles == nil || f.Styles.NumFmts == nil:
+		return 0, false
+	}
+	for _, numFmt := range f.Styles.NumFmts.NumFmt {
+		if numFmt.NumFmtID != numFmtID {
+			continue
+		}
+		code := strings.ToLower(numFmtLiteralReg.ReplaceAllString(numFmt.FormatCode, ""))
+		if strings.Contains(code, "d") {
+			return ods.Date, true
+		} else if strings.Contains(code, "y") {
+			return ods.Month, true
+		}
+	}
+	return 0, false
+}
+
+// odsCell get typed cell of raw cell, amount and count numbers are kept in their formats,
+// dates are written as dates and other numbers as they are
+func odsCell(f *excelize.File, book xlsxBook, sheet string, raw rawCell, text string) ods.Cell {
+	col, row, _ := excelize.CellNameToCoordinates(raw.Ref)
+	cell := ods.Cell{Value: text}
+	if raw.Formula != nil {
+		cell.Formula, _ = f.GetCellFormula(sheet, raw.Ref)
+	}
+	if raw.Type != "" && raw.Type != "n" || raw.Value == "" {
+		return cell // text result of formula
+	}
+	serial, err := strconv.ParseFloat(raw.Value, 64)
+	if err != nil {
+		return cell
+	}
+	cell.Value = raw.Value
+	numFmtID := book.NumFmt(sheet, col, row)
+	switch numFmtID {
+	case numFmtCount:
+		cell.Type = ods.Integer
+		return cell
+	case numFmtAmount:
+		cell.Type = ods.Decimal
+		return cell
+	}
+	cell.Type = ods.Float
+	if typ, ok := dateType(f, numFmtID); ok {
+		if t, err := excelize.ExcelDateToTime(serial, false); err == nil {
+			cell.Type, cell.Value = typ, t.Format("2006-01-02T15:04:05")
+			if t.Equal(t.Truncate(24 * time.Hour)) {
+				cell.Value = t.Format("2006-01-02")
+			}
+		}
+	}
+	return cell
+}
+
+// writeOds write all sheets of saved dst file into ods file beside it,
+// numbers and dates are kept as they are in xlsx with formulas, others are written as text shown in xlsx
+func (c *Collect) writeOds(filename string) error {
+	// styles of sparse rows are only right when read from file
+	f, err := excelize.OpenFile(filepath.Join(c.dstDir, filename))
+	if err != nil {
+		return err
+	}
+	book := xlsxBook{f}
+	tables := make([]ods.Table, 0, len(f.GetSheetList()))
+	for _, sheetName := range f.GetSheetList() {
+		raws, err := rawCells(f, sheetName)
+		if err != nil {
+			return err
+		}
+		rows, err := f.GetRows(sheetName)
+		if err != nil {
+			return err
+		}
+		table := ods.Table{Name: sheetName, Hidden: !f.GetSheetVisible(sheetName), Rows: make([][]ods.Cell, len(rows))}
+		for row, values := range rows {
+			table.Rows[row] = make([]ods.Cell, len(values))
+			for col, value := range values {
+				table.Rows[row][col] = ods.Cell{Value: value}
+			}
+		}
+		for _, raw := range raws {
+			col, row, err := excelize.CellNameToCoordinates(raw.Ref)
+			if err != nil {
+				continue
+			}
+			for len(table.Rows) < row {
+				table.Rows = append(table.Rows, nil)
+			}
+			for len(table.Rows[row-1]) < col {
+				table.Rows[row-1] = append(table.Rows[row-1], ods.Cell{})
+			}
+			text := table.Rows[row-1][col-1].Value
+			table.Rows[row-1][col-1] = odsCell(f, book, sheetName, raw, text)
+		}
+		tables = append(tables, table)
+	}
+
+	path := filepath.Join(c.dstDir, odsName(filename))
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := ods.Write(out, tables); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
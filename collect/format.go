@@ -0,0 +1,395 @@
+// code for formatting dst sheets by theme of config: excel table with autofilter, styled and frozen header,
+// and column widths measured from content, sheets of existing dst file in append mode keep their look,
+// only their tables and autofilters are resized to rows and cols appended
+
+package collect
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"excel/config"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/width"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	workbookPart = "xl/workbook.xml"
+	relTypeTable = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/table"
+	filterDBName = "_xlnm._FilterDatabase"
+	minTableRows = 2 // header and an empty row, for sheet without data yet
+)
+
+var (
+	tableRefReg     = regexp.MustCompile(`^(<table\b[^>]*?\s)ref="([^"]*)"`)
+	filterRefReg    = regexp.MustCompile(`<autoFilter ref="[^"]*"`)
+	tableColumnReg  = regexp.MustCompile(`<tableColumn\b[^>]*?\sid="(\d+)"`)
+	columnsCountReg = regexp.MustCompile(`<tableColumns count="\d+"`)
+)
+
+type partRel struct {
+	ID     string `xml:"Id,attr"`
+	Type   string `xml:"Type,attr"`
+	Target string `xml:"Target,attr"`
+}
+
+type partRels struct {
+	Rels []partRel `xml:"Relationship"`
+}
+
+// relsPath get path of rels of part
+func relsPath(part string) string {
+	dir, base := path.Split(part)
+	return dir + "_rels/" + base + ".rels"
+}
+
+// relTarget get path of target relative to part, or absolute in package
+func relTarget(part, target string) string {
+	if strings.HasPrefix(target, "/") {
+		return strings.TrimPrefix(target, "/")
+	}
+	return path.Join(path.Dir(part), target)
+}
+
+// displayWidth get width of text in column, CJK chars count twice, the longest line of multi-line text
+func displayWidth(text string) float64 {
+	longest := 0
+	for _, line := range strings.Split(text, "\n") {
+		n := 0
+		for _, r := range line {
+			switch width.LookupRune(r).Kind() {
+			case width.EastAsianWide, width.EastAsianFullwidth:
+				n += 2
+			default:
+				n++
+			}
+		}
+		longest = max(longest, n)
+	}
+	return float64(longest)
+}
+
+// tableHeader check whether header can be used by excel table, cells must be unique and not blank
+func tableHeader(header []string) bool {
+	seen := make(map[string]bool, len(header))
+	for _, colData := range header {
+		colData = strings.TrimSpace(colData)
+		if colData == "" || seen[colData] {
+			return false
+		}
+		seen[colData] = true
+	}
+	return true
+}
+
+// formatSheet format sheet with header in the first row, a total row at the end is not in table,
+// sheet without header only gets column widths
+func formatSheet(f *excelize.File, theme *config.Theme, sheet string, total bool) error {
+	rows, err := f.GetRows(sheet)
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+
+	// column widths, with room for filter button
+	widths := make([]float64, 0)
+	for _, row := range rows {
+		for col, colData := range row {
+			if col >= len(widths) {
+				widths = append(widths, theme.MinWidth)
+			}
+			widths[col] = max(widths[col], displayWidth(colData)+2)
+		}
+	}
+	// excelize puts the new col first, so cols are set from the last to keep them in order
+	for col := len(widths) - 1; col >= 0; col-- {
+		colName, _ := excelize.ColumnNumberToName(col + 1)
+		if err := f.SetColWidth(sheet, colName, colName, min(widths[col], theme.MaxWidth)); err != nil {
+			return err
+		}
+	}
+
+	header := rows[0]
+	if strings.TrimSpace(strings.Join(header, "")) == "" {
+		return nil
+	}
+	lastCol, _ := excelize.ColumnNumberToName(len(header))
+	if style := headerStyle(theme); style != nil {
+		styleID, err := f.NewStyle(style)
+		if err != nil {
+			return err
+		}
+		if err := f.SetCellStyle(sheet, "A1", lastCol+"1", styleID); err != nil {
+			return err
+		}
+	}
+	if theme.Freeze {
+		if err := f.SetPanes(sheet, `{"freeze":true,"split":false,"x_split":0,"y_split":1,"top_left_cell":"A2","active_pane":"bottomLeft"}`); err != nil {
+			return err
+		}
+	}
+
+	lastRow := len(rows)
+	if total {
+		lastRow--
+	}
+	// table of sheet updated in place is kept, it may be used by formulas of other sheets
+	if ok, err := fitTables(f, sheet, header, max(lastRow, minTableRows)); err != nil || ok {
+		return err
+	}
+	if lastRow < minTableRows {
+		if total {
+			return nil // no data
+		}
+		lastRow = minTableRows // resized in later runs
+	}
+	vcell := fmt.Sprintf("%s%d", lastCol, lastRow)
+	if theme.TableStyle == "" || !tableHeader(header) {
+		return f.AutoFilter(sheet, "A1", vcell, "")
+	}
+	format, err := json.Marshal(map[string]interface{}{"table_style": theme.TableStyle, "show_row_stripes": true})
+	if err != nil {
+		return err
+	}
+	return f.AddTable(sheet, "A1", vcell, string(format))
+}
+
+// loadRels read relationships of part in f, whether excelize has parsed them or not
+func loadRels(f *excelize.File, part string) (*partRels, error) {
+	rels := &partRels{}
+	var content []byte
+	if parsed, ok := f.Relationships.Load(relsPath(part)); ok && parsed != nil {
+		var err error
+		if content, err = xml.Marshal(parsed); err != nil {
+			return nil, err
+		}
+	} else if raw, ok := f.Pkg.Load(relsPath(part)); ok {
+		content = raw.([]byte)
+	} else {
+		return rels, nil
+	}
+	return rels, xml.Unmarshal(content, rels)
+}
+
+// sheetPart get part of worksheet of sheet, empty if not found
+func sheetPart(f *excelize.File, sheet string) (string, error) {
+	f.GetSheetList() // workbook is read
+	id := ""
+	for _, s := range f.WorkBook.Sheets.Sheet {
+		if s.Name == sheet {
+			id = s.ID
+		}
+	}
+	wbRels, err := loadRels(f, workbookPart)
+	if err != nil || id == "" {
+		return "", err
+	}
+	for _, rel := range wbRels.Rels {
+		if rel.ID == id {
+			return relTarget(workbookPart, rel.Target), nil
+		}
+	}
+	return "", nil
+}
+
+// partTargets get rels of type from part, with targets as paths in package
+func partTargets(f *excelize.File, part, relType string) ([]partRel, error) {
+	rels, err := loadRels(f, part)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]partRel, 0)
+	for _, rel := range rels.Rels {
+		if rel.Type == relType {
+			rel.Target = relTarget(part, rel.Target)
+			targets = append(targets, rel)
+		}
+	}
+	return targets, nil
+}
+
+// sheetTargets get rels of type from worksheet of sheet
+func sheetTargets(f *excelize.File, sheet, relType string) ([]partRel, error) {
+	sheetPart, err := sheetPart(f, sheet)
+	if err != nil || sheetPart == "" {
+		return nil, err
+	}
+	return partTargets(f, sheetPart, relType)
+}
+
+// sheetTables get parts of tables on sheet
+func sheetTables(f *excelize.File, sheet string) ([]string, error) {
+	rels, err := sheetTargets(f, sheet, relTypeTable)
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]string, 0, len(rels))
+	for _, rel := range rels {
+		tables = append(tables, rel.Target)
+	}
+	return tables, nil
+}
+
+// resizeTable set range of table at A1 to lastRow, and cols of header appended after its cols,
+// header of table can not be blank or repeated, so cols are not added if header has them
+func resizeTable(content []byte, header []string, lastRow int) []byte {
+	start := bytes.Index(content, []byte("<table"))
+	if start == -1 {
+		return content
+	}
+	if !ownTable(content[start:]) {
+		return content // not made by us
+	}
+	cols := tableColumnReg.FindAllSubmatch(content, -1)
+	width, lastID := len(cols), 0
+	for _, col := range cols {
+		id, _ := strconv.Atoi(string(col[1]))
+		lastID = max(lastID, id)
+	}
+	if len(header) > width && tableHeader(header) {
+		added := &bytes.Buffer{}
+		for _, name := range header[width:] {
+			lastID++
+			added.WriteString(`<tableColumn id="` + strconv.Itoa(lastID) + `" name="`)
+			xml.EscapeText(added, []byte(strings.TrimSpace(name)))
+			added.WriteString(`"></tableColumn>`)
+		}
+		width = len(header)
+		content = bytes.Replace(content, []byte("</tableColumns>"), append(added.Bytes(), "</tableColumns>"...), 1)
+		content = columnsCountReg.ReplaceAll(content, []byte(`<tableColumns count="`+strconv.Itoa(width)+`"`))
+	}
+	lastCol, _ := excelize.ColumnNumberToName(width)
+	ref := fmt.Sprintf("A1:%s%d", lastCol, lastRow)
+	start = bytes.Index(content, []byte("<table"))
+	tag := tableRefReg.ReplaceAll(content[start:], []byte(`${1}ref="`+ref+`"`))
+	content = append(append([]byte{}, content[:start]...), tag...)
+	return filterRefReg.ReplaceAll(content, []byte(`<autoFilter ref="`+ref+`"`))
+}
+
+// ownTable check whether table is at A1, as tables made by us
+func ownTable(content []byte) bool {
+	match := tableRefReg.FindSubmatch(content)
+	return match != nil && strings.HasPrefix(string(match[2]), "A1:")
+}
+
+// setTableColumns replace cols of table with header, for sheet updated in place whose cols may change
+func setTableColumns(content []byte, header []string) []byte {
+	start := bytes.Index(content, []byte("<tableColumns"))
+	end := bytes.Index(content, []byte("</tableColumns>"))
+	if start == -1 || end < start {
+		return content
+	}
+	cols := &bytes.Buffer{}
+	cols.WriteString(`<tableColumns count="` + strconv.Itoa(len(header)) + `">`)
+	for i, name := range header {
+		cols.WriteString(`<tableColumn id="` + strconv.Itoa(i+1) + `" name="`)
+		xml.EscapeText(cols, []byte(strings.TrimSpace(name)))
+		cols.WriteString(`"></tableColumn>`)
+	}
+	replaced := append(append([]byte{}, content[:start]...), cols.Bytes()...)
+	return append(replaced, content[end:]...)
+}
+
+// fitTables set cols of tables at A1 of sheet to header and their range to lastRow, false if sheet has no table
+func fitTables(f *excelize.File, sheet string, header []string, lastRow int) (bool, error) {
+	tables, err := sheetTables(f, sheet)
+	if err != nil || len(tables) == 0 {
+		return false, err
+	}
+	for _, table := range tables {
+		content, ok := f.Pkg.Load(table)
+		if !ok || !tableHeader(header) {
+			continue
+		}
+		if start := bytes.Index(content.([]byte), []byte("<table")); start != -1 && ownTable(content.([]byte)[start:]) {
+			f.Pkg.Store(table, resizeTable(setTableColumns(content.([]byte), header), header, lastRow))
+		}
+	}
+	return true, nil
+}
+
+// resizeSheet resize tables and autofilter of existing sheet to its rows and cols
+func resizeSheet(f *excelize.File, sheet string) error {
+	rows, err := f.GetRows(sheet)
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+	header, lastRow := rows[0], max(len(rows), minTableRows)
+	tables, err := sheetTables(f, sheet)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if content, ok := f.Pkg.Load(table); ok {
+			f.Pkg.Store(table, resizeTable(content.([]byte), header, lastRow))
+		}
+	}
+	for _, name := range f.GetDefinedName() {
+		if name.Name == filterDBName && name.Scope == sheet {
+			lastCol, _ := excelize.ColumnNumberToName(len(header))
+			return f.AutoFilter(sheet, "A1", fmt.Sprintf("%s%d", lastCol, lastRow), "")
+		}
+	}
+	return nil
+}
+
+// headerStyle get style of header row by theme, nil if theme has no style of header
+func headerStyle(theme *config.Theme) *excelize.Style {
+	if theme.HeaderFill == "" && theme.HeaderColor == "" && !theme.HeaderBold {
+		return nil
+	}
+	style := &excelize.Style{
+		Font:      &excelize.Font{Bold: theme.HeaderBold, Color: theme.HeaderColor},
+		Alignment: &excelize.Alignment{Vertical: "center"},
+	}
+	if theme.HeaderFill != "" {
+		style.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{theme.HeaderFill}}
+	}
+	return style
+}
+
+// formatSheets format dst sheets of tasks created in this run, and rollup sheets updated in every run,
+// tables of other dst sheets of tasks are resized whether theme is enabled or not
+func (c *Collect) formatSheets(f *excelize.File) error {
+	theme := c.conf.Theme
+	for _, task := range c.tasks() {
+		schema, ok := c.conf.Schema[task]
+		if !ok || f.GetSheetIndex(schema.Target) == -1 {
+			continue
+		}
+		var err error
+		if !c.newSheets[schema.Target] {
+			err = resizeSheet(f, schema.Target)
+		} else if theme != nil && theme.Enable {
+			err = formatSheet(f, theme, schema.Target, false)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", schema.Target, err)
+		}
+	}
+	if !c.conf.Rollup {
+		return nil
+	}
+	for _, sheet := range []string{rollupSheet, contentRollupSheet} {
+		if f.GetSheetIndex(sheet) == -1 {
+			continue
+		}
+		var err error
+		if theme != nil && theme.Enable {
+			err = formatSheet(f, theme, sheet, true)
+		} else if rows, e := f.GetRows(sheet); e != nil {
+			err = e
+		} else if len(rows) != 0 {
+			// tables of earlier runs with theme are kept fit to rows
+			_, err = fitTables(f, sheet, rows[0], max(len(rows)-1, minTableRows))
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", sheet, err)
+		}
+	}
+	return nil
+}
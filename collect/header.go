@@ -0,0 +1,140 @@
+// code for union of src headers by name, so src files with extra or reordered cols of common sheets
+// are written under the dst cols of the same name, cols only in some files are reported
+
+package collect
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const reasonPartialCols = "缺少其他文件中的列：%s，留空"
+
+// headerKeys get key of each header col, a name appears more than once is keyed with occurrence, such as "备注#2"
+func headerKeys(header []string) []string {
+	keys := make([]string, len(header))
+	seen := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		seen[name]++
+		if seen[name] > 1 {
+			keys[i] = name + "#" + strconv.Itoa(seen[name])
+		} else {
+			keys[i] = name
+		}
+	}
+	return keys
+}
+
+// padHeader extend header with blank names to width, for rows longer than header
+func padHeader(header []string, width int) []string {
+	if len(header) >= width {
+		return header
+	}
+	padded := make([]string, width)
+	copy(padded, header)
+	return padded
+}
+
+// headerUnion is header of dst sheet, union of src headers in order of first appearance
+type headerUnion struct {
+	header []string                   // names of dst cols
+	cols   map[string]int             // key, dst col index start from zero
+	files  map[string]map[string]bool // key, src files having this col
+	fnames []string                   // src files aligned, in order
+}
+
+// newHeaderUnion start union from header of existing dst sheet, nil for empty sheet
+func newHeaderUnion(header []string) *headerUnion {
+	u := &headerUnion{cols: make(map[string]int), files: make(map[string]map[string]bool)}
+	u.align("", header)
+	return u
+}
+
+// align get dst col index of each col of src header, names not in dst header are appended,
+// added are src col indexes of them
+func (u *headerUnion) align(fname string, header []string) (cols []int, added []int) {
+	if fname != "" && indexOf(u.fnames, fname) == -1 {
+		u.fnames = append(u.fnames, fname)
+	}
+	cols = make([]int, len(header))
+	for i, key := range headerKeys(header) {
+		col, ok := u.cols[key]
+		if !ok {
+			col = len(u.header)
+			u.cols[key] = col
+			u.header = append(u.header, strings.TrimSpace(header[i]))
+			added = append(added, i)
+		}
+		cols[i] = col
+		if fname != "" {
+			if u.files[key] == nil {
+				u.files[key] = make(map[string]bool)
+			}
+			u.files[key][fname] = true
+		}
+	}
+	return cols, added
+}
+
+// partialCols get cols each src file lacks but other src files have, blank names are not reported
+func (u *headerUnion) partialCols() map[string][]string {
+	missing := make(map[string][]string)
+	keys := headerKeys(u.header)
+	for _, fname := range u.fnames {
+		for _, key := range keys {
+			files := u.files[key]
+			if strings.TrimSpace(u.header[u.cols[key]]) == "" || len(files) == 0 || files[fname] {
+				continue
+			}
+			missing[fname] = append(missing[fname], key)
+		}
+	}
+	return missing
+}
+
+// reportPartialCols record src files of task lacking cols other files have
+func (c *Collect) reportPartialCols(task, sheet string, u *headerUnion) {
+	missing := u.partialCols()
+	for _, fname := range u.fnames {
+		if cols, ok := missing[fname]; ok {
+			c.report.Add(ReportEntry{Task: task, File: fname, Sheet: sheet, Reason: fmt.Sprintf(reasonPartialCols, strings.Join(cols, "、")), Kept: true})
+		}
+	}
+}
+
+// mergeCols find src col of each field in header merged from sheets of the same file, the first matching col wins,
+// but an optional field matching more than one col is left out as findCols does
+func (s *Sheet) mergeCols() {
+	s.cols = make(map[string]int)
+	for i := range s.schema.Columns {
+		column := &s.schema.Columns[i]
+		if column.Header == "" {
+			continue
+		}
+		found := 0
+		for id, colData := range s.header {
+			if matchHeader(colData, column) {
+				if found == 0 {
+					s.cols[column.Field] = id
+				}
+				found++
+			}
+		}
+		if found > 1 && column.Optional {
+			delete(s.cols, column.Field)
+		}
+	}
+}
+
+// remapRow move cols of row to dst col indexes
+func remapRow(colsData []string, cols []int, width int) []string {
+	row := make([]string, width)
+	for i, colData := range colsData {
+		if i < len(cols) {
+			row[cols[i]] = colData
+		}
+	}
+	return row
+}
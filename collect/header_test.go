@@ -0,0 +1,128 @@
+package collect
+
+import (
+	"github.com/xuri/excelize/v2"
+	"reflect"
+	"testing"
+)
+
+func TestHeaderKeys(t *testing.T) {
+	header := []string{"备注", "金额", "备注", " 备注 ", "", " "}
+	want := []string{"备注", "金额", "备注#2", "备注#3", "", "#2"}
+	if got := headerKeys(header); !reflect.DeepEqual(got, want) {
+		t.Errorf("headerKeys(%q) = %q, want %q", header, got, want)
+	}
+}
+
+func TestHeaderUnionAlign(t *testing.T) {
+	type step struct {
+		fname  string
+		header []string
+		cols   []int
+		added  []int
+	}
+	tests := []struct {
+		name    string
+		dst     []string // header of existing dst sheet
+		steps   []step
+		header  []string
+		partial map[string][]string
+	}{
+		{
+			name: "same header",
+			steps: []step{
+				{"a.xlsx", []string{"名称", "金额"}, []int{0, 1}, []int{0, 1}},
+				{"b.xlsx", []string{"名称", "金额"}, []int{0, 1}, nil},
+			},
+			header: []string{"名称", "金额"}, partial: map[string][]string{},
+		},
+		{
+			name: "reordered and extra cols",
+			steps: []step{
+				{"a.xlsx", []string{"名称", "部门", "金额"}, []int{0, 1, 2}, []int{0, 1, 2}},
+				{"b.xlsx", []string{"金额", " 名称", "备注"}, []int{2, 0, 3}, []int{2}},
+			},
+			header:  []string{"名称", "部门", "金额", "备注"},
+			partial: map[string][]string{"a.xlsx": {"备注"}, "b.xlsx": {"部门"}},
+		},
+		{
+			name: "repeated names by occurrence",
+			steps: []step{
+				{"a.xlsx", []string{"备注", "金额", "备注"}, []int{0, 1, 2}, []int{0, 1, 2}},
+				{"b.xlsx", []string{"金额", "备注"}, []int{1, 0}, nil},
+			},
+			header:  []string{"备注", "金额", "备注"},
+			partial: map[string][]string{"b.xlsx": {"备注#2"}},
+		},
+		{
+			name: "existing dst header",
+			dst:  []string{"名称", "金额"},
+			steps: []step{
+				{"a.xlsx", []string{"金额", "名称", "部门"}, []int{1, 0, 2}, []int{2}},
+			},
+			// cols of dst header only are not reported, no src file has them
+			header: []string{"名称", "金额", "部门"}, partial: map[string][]string{},
+		},
+		{
+			name: "blank names are not reported",
+			steps: []step{
+				{"a.xlsx", []string{"名称", ""}, []int{0, 1}, []int{0, 1}},
+				{"b.xlsx", []string{"名称"}, []int{0}, nil},
+			},
+			header: []string{"名称", ""}, partial: map[string][]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := newHeaderUnion(tt.dst)
+			for _, step := range tt.steps {
+				cols, added := u.align(step.fname, step.header)
+				if !reflect.DeepEqual(cols, step.cols) || !reflect.DeepEqual(added, step.added) {
+					t.Errorf("align(%s, %q) = %v, %v, want %v, %v", step.fname, step.header, cols, added, step.cols, step.added)
+				}
+			}
+			if !reflect.DeepEqual(u.header, tt.header) {
+				t.Errorf("header = %q, want %q", u.header, tt.header)
+			}
+			if got := u.partialCols(); !reflect.DeepEqual(got, tt.partial) {
+				t.Errorf("partialCols = %q, want %q", got, tt.partial)
+			}
+		})
+	}
+}
+
+// TestWriteSheetAllUnion check rows of src files with different headers are written under dst cols of the same name
+func TestWriteSheetAllUnion(t *testing.T) {
+	f := excelize.NewFile()
+	dst := &Sheet{name: "活动", row: 1, col: 1, file: f, union: newHeaderUnion(nil)}
+	srcs := []*Sheet{
+		{fileName: "a.xlsx", header: []string{"名称", "部门", "金额"}, data: [][]string{{"x1", "d1", "100"}}},
+		{fileName: "b.xlsx", header: []string{"金额", "名称", "备注"}, data: [][]string{{"200", "x2", "note"}}},
+		{fileName: "c.xlsx", header: []string{"名称", "部门", "金额", "金额"},
+			data: [][]string{{"x3", "d3", "1", "2"}, {"x4", "d4", "3", "4", "extra"}}}, // row longer than header
+	}
+	for _, src := range srcs {
+		src.cols = make(map[string]int)
+		if err := dst.WriteSheetAll(src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows, err := f.GetRows("活动")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"名称", "部门", "金额", "备注", "金额"}, // blank name of padded col is not written
+		{"x1", "d1", "100"},
+		{"x2", "", "200", "note"},
+		{"x3", "d3", "1", "", "2"},
+		{"x4", "d4", "3", "", "4", "extra"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+	wantPartial := map[string][]string{"a.xlsx": {"备注", "金额#2"}, "b.xlsx": {"部门", "金额#2"}, "c.xlsx": {"备注"}}
+	if got := dst.union.partialCols(); !reflect.DeepEqual(got, wantPartial) {
+		t.Errorf("partialCols = %q, want %q", got, wantPartial)
+	}
+}
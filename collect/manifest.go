@@ -0,0 +1,177 @@
+// code for remember which src files have been collected into dst file
+// manifest is kept in a hidden sheet of dst file, so append mode only collects new src files
+
+package collect
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/xuri/excelize/v2"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	manifestSheet = "_manifest"
+
+	reasonChanged = "源文件内容已变更，追加模式不重复收集"
+)
+
+var manifestHeader = []string{"任务", "文件", "哈希", "处理时间"}
+
+type manifestEntry struct {
+	hash string // sha256 of src file
+	time string // when it was collected
+}
+
+// manifest is safe for concurrent use by tasks
+type manifest struct {
+	mu      sync.Mutex
+	entries map[string]map[string]manifestEntry // task, file name, entry
+}
+
+func newManifest() *manifest {
+	return &manifest{entries: make(map[string]map[string]manifestEntry)}
+}
+
+func fileHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// loadManifest read manifest sheet of f, empty manifest if f has no manifest sheet
+func loadManifest(f *excelize.File) (*manifest, error) {
+	m := newManifest()
+	if f.GetSheetIndex(manifestSheet) == -1 {
+		return m, nil
+	}
+	rows, err := f.GetRows(manifestSheet)
+	if err != nil {
+		return nil, err
+	}
+	for id, row := range rows {
+		if id == 0 || len(row) < len(manifestHeader) {
+			continue // header or broken row
+		}
+		m.add(row[0], row[1], manifestEntry{hash: row[2], time: row[3]})
+	}
+	return m, nil
+}
+
+func (m *manifest) add(task, file string, entry manifestEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.entries[task] == nil {
+		m.entries[task] = make(map[string]manifestEntry)
+	}
+	m.entries[task][file] = entry
+}
+
+// lookup return whether file of task has been collected, and whether its content changed since then
+func (m *manifest) lookup(task, file, hash string) (seen, changed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[task][file]
+	if !ok {
+		return false, false
+	}
+	return true, entry.hash != hash
+}
+
+// write replace manifest sheet of f with all entries, the sheet is hidden
+func (m *manifest) write(f *excelize.File) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f.GetSheetIndex(manifestSheet) != -1 {
+		f.DeleteSheet(manifestSheet)
+	}
+	f.NewSheet(manifestSheet)
+	records := [][]string{manifestHeader}
+	tasks := make([]string, 0, len(m.entries))
+	for task := range m.entries {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+	for _, task := range tasks {
+		files := make([]string, 0, len(m.entries[task]))
+		for file := range m.entries[task] {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			entry := m.entries[task][file]
+			records = append(records, []string{task, file, entry.hash, entry.time})
+		}
+	}
+	for row, record := range records {
+		axis, _ := excelize.CoordinatesToCellName(1, row+1)
+		if err := f.SetSheetRow(manifestSheet, axis, &record); err != nil {
+			return err
+		}
+	}
+	return f.SetSheetVisible(manifestSheet, false)
+}
+
+// srcNamesOf return src files which task should collect, in append mode files collected before are left out
+func (c *Collect) srcNamesOf(task string) []string {
+	if !c.conf.Append {
+		return c.srcNames
+	}
+	names := make([]string, 0, len(c.srcNames))
+	for _, fname := range c.srcNames {
+		seen, changed := c.manifest.lookup(task, fname, c.srcHashes[fname])
+		if changed {
+			c.report.Add(ReportEntry{Task: task, File: fname, Reason: reasonChanged})
+		}
+		if !seen {
+			names = append(names, fname)
+		}
+	}
+	return names
+}
+
+// markCollected record files of task into manifest, except the files failed in err
+func (c *Collect) markCollected(task string, fnames []string, err error) {
+	if err != nil {
+		if _, ok := err.(RunError); !ok {
+			return // task aborted, nothing is sure to be collected
+		}
+	}
+	failed := make(map[string]bool)
+	if runErr, ok := err.(RunError); ok {
+		for _, taskErr := range runErr {
+			failed[taskErr.File] = true
+		}
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	for _, fname := range fnames {
+		if !failed[fname] {
+			c.manifest.add(task, fname, manifestEntry{hash: c.srcHashes[fname], time: now})
+		}
+	}
+}
+
+// dstLastRow return number of the last row which has data in dst sheet, zero if sheet is empty
+func (c *Collect) dstLastRow(filename, sheetName string) (int, error) {
+	lastRow := 0
+	err := c.dstWriters[filename].Do(func(f *excelize.File) error {
+		rows, err := f.GetRows(sheetName)
+		lastRow = len(rows)
+		return err
+	})
+	return lastRow, err
+}
+
+// dstHeader return the first row of dst sheet, nil if sheet is empty
+func (c *Collect) dstHeader(filename, sheetName string) ([]string, error) {
+	var header []string
+	err := c.dstWriters[filename].Do(func(f *excelize.File) error {
+		rows, err := f.GetRows(sheetName)
+		if len(rows) > 0 {
+			header = rows[0]
+		}
+		return err
+	})
+	return header, err
+}
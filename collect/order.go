@@ -0,0 +1,93 @@
+// code for make output order the same in every run
+// src files are sorted by name, period in name or modify time
+// rows can be sorted by fields of schema before written
+
+package collect
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// order of src files
+	orderByName  = "name"
+	orderByMonth = "month"
+	orderByMtime = "mtime"
+)
+
+// sortSrcFiles sort file names by order, ties and files without period are sorted by name
+func sortSrcFiles(names []string, order string, mtimes map[string]time.Time, periods map[string]period) {
+	sort.Strings(names)
+	switch order {
+	case orderByMonth:
+		sort.SliceStable(names, func(i, j int) bool {
+			pi, oki := periods[names[i]]
+			pj, okj := periods[names[j]]
+			if oki != okj {
+				return oki // files without period at the end
+			}
+			return pi.key() < pj.key()
+		})
+	case orderByMtime:
+		sort.SliceStable(names, func(i, j int) bool {
+			return mtimes[names[i]].Before(mtimes[names[j]])
+		})
+	}
+}
+
+// lessValue compare as number if both are number, such as "1,000" or "1.2万", otherwise as string
+func lessValue(a, b string) bool {
+	fa, errA := parseQuantity(a)
+	fb, errB := parseQuantity(b)
+	if errA == nil && errB == nil {
+		return fa < fb
+	}
+	return a < b
+}
+
+// sortValue get value of field to sort, month comes from file name
+func (s *Sheet) sortValue(colsData []string, field string) string {
+	if field == monthD {
+		return strconv.Itoa(s.period.key())
+	}
+	return s.colValue(colsData, field)
+}
+
+// sortRows split sheets into sheets of one row, sorted by fields,
+// rows with the same fields keep the order of sheets
+func sortRows(sheets []*Sheet, fields []string) []*Sheet {
+	rows := make([]*Sheet, 0)
+	for _, sheet := range sheets {
+		for _, colsData := range sheet.data {
+			row := *sheet
+			row.data = [][]string{colsData}
+			rows = append(rows, &row)
+		}
+		if len(sheet.data) == 0 && sheet.header != nil {
+			// no data but header
+			row := *sheet
+			rows = append(rows, &row)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, field := range fields {
+			a := rows[i].sortValue(rows[i].data0(), field)
+			b := rows[j].sortValue(rows[j].data0(), field)
+			if a != b {
+				return lessValue(a, b)
+			}
+		}
+		return false
+	})
+	return rows
+}
+
+// data0 return the only row of sheet split by sortRows
+func (s *Sheet) data0() []string {
+	if len(s.data) == 0 {
+		return nil
+	}
+	return s.data[0]
+}
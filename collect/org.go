@@ -0,0 +1,295 @@
+// code for resolving organization("机构") of uid from all files which suffix is "csv"
+// a uid assigned more than once is resolved by rules of config, see "机构冲突" sheet for every conflict
+// org of content row is resolved as of its month by default, rows added after the month are not used
+
+package collect
+
+import (
+	"fmt"
+	"github.com/gocarina/gocsv"
+	"github.com/xuri/excelize/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// reasons of org row not chosen, by rule of config
+	reasonKolType = "机构类型优先级较低"
+	reasonDate    = "添加日期较早"
+	reasonFile    = "文件优先级较低"
+	reasonFirst   = "各规则相同，取先出现的"
+)
+
+var orgRuleReasons = map[string]string{
+	"kol_type": reasonKolType,
+	"date":     reasonDate,
+	"file":     reasonFile,
+}
+
+var orgConflictHeader = []string{"UID", "机构", "添加日期", "文件", "行号", "结果", "原因"}
+
+// layouts of add_date in csv files
+var orgDateLayouts = []string{"20060102", "2006-01-02", "2006/1/2", "2006.1.2"}
+
+type Org struct {
+	KolType string `csv:"kol_type"`
+	Uid     string `csv:"uid"`
+	AddDate string `csv:"add_date"`
+}
+
+// orgRow is a row of csv file
+type orgRow struct {
+	Org
+	file string
+	line int // line number in file, header is line one
+}
+
+// orgConflict is a uid assigned to different orgs, rows are in order of files and lines
+type orgConflict struct {
+	uid     string
+	rows    []*orgRow
+	chosen  *orgRow
+	reasons map[*orgRow]string // row not chosen, reason
+}
+
+// orgTable is orgs of uids read from csv files
+type orgTable struct {
+	resolver *orgResolver         // rules of config, for latest and conflicts
+	monthly  *orgResolver         // the latest add_date first, for org as of a month
+	rows     map[string][]*orgRow // uid, rows in order of files and lines
+	latest   map[string]string    // uid, org resolved by all rows
+	byMonth  bool                 // resolve org as of month of content row
+}
+
+// lookup get org of uid as of period, false if uid has no org then
+func (t *orgTable) lookup(uid string, p period) (string, bool) {
+	if !t.byMonth {
+		org, ok := t.latest[uid]
+		return org, ok
+	}
+	rows := make([]*orgRow, 0, len(t.rows[uid]))
+	for _, row := range t.rows[uid] {
+		// row without add_date is always valid
+		if date, ok := orgDate(row.AddDate); !ok || date.Before(p.end()) {
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) == 0 {
+		return "", false
+	}
+	chosen, _ := t.monthly.choose(rows)
+	return chosen.KolType, true
+}
+
+// orgResolver choose org of uid by rules of config
+type orgResolver struct {
+	rules    []string
+	kolTypes []string
+	files    map[string]int // file name, rank
+}
+
+func newOrgResolver(rules, kolTypes, files, fnames []string) *orgResolver {
+	r := &orgResolver{rules: rules, kolTypes: kolTypes, files: make(map[string]int)}
+	rank := 0
+	for _, file := range files {
+		for _, fname := range fnames {
+			if _, ok := r.files[fname]; !ok && strings.EqualFold(file, fname) {
+				r.files[fname] = rank
+				rank++
+			}
+		}
+	}
+	for _, fname := range fnames { // sorted by name
+		if _, ok := r.files[fname]; !ok {
+			r.files[fname] = rank
+			rank++
+		}
+	}
+	return r
+}
+
+// dateFirst get resolver with the same ranks, but the latest add_date comes first,
+// other rules only decide rows of the same date
+func (r *orgResolver) dateFirst() *orgResolver {
+	rules := []string{"date"}
+	for _, rule := range r.rules {
+		if rule != "date" {
+			rules = append(rules, rule)
+		}
+	}
+	return &orgResolver{rules: rules, kolTypes: r.kolTypes, files: r.files}
+}
+
+// kolTypeRank get index of the first keyword kol_type contains, or index of "*"
+func (r *orgResolver) kolTypeRank(kolType string) int {
+	others := len(r.kolTypes)
+	for i, keyword := range r.kolTypes {
+		if keyword == "*" {
+			others = i
+		} else if strings.Contains(kolType, keyword) {
+			return i
+		}
+	}
+	return others
+}
+
+// orgDate parse add_date, ok is false if it is empty or invalid
+func orgDate(raw string) (time.Time, bool) {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range orgDateLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// compare return negative if a is better than b, positive if b is better, and the rule decides it
+func (r *orgResolver) compare(a, b *orgRow) (int, string) {
+	for _, rule := range r.rules {
+		var rankA, rankB int
+		switch rule {
+		case "kol_type":
+			rankA, rankB = r.kolTypeRank(a.KolType), r.kolTypeRank(b.KolType)
+		case "date":
+			dateA, okA := orgDate(a.AddDate)
+			dateB, okB := orgDate(b.AddDate)
+			switch {
+			case okA && okB:
+				rankA, rankB = -int(dateA.Unix()/86400), -int(dateB.Unix()/86400)
+			case okA:
+				rankA, rankB = 0, 1 // valid date wins
+			case okB:
+				rankA, rankB = 1, 0
+			}
+		case "file":
+			rankA, rankB = r.files[a.file], r.files[b.file]
+		}
+		if rankA != rankB {
+			return rankA - rankB, rule
+		}
+	}
+	return 0, ""
+}
+
+// choose get the best row, and why others are not chosen
+func (r *orgResolver) choose(rows []*orgRow) (*orgRow, map[*orgRow]string) {
+	chosen := rows[0]
+	for _, row := range rows[1:] {
+		if order, _ := r.compare(row, chosen); order < 0 {
+			chosen = row
+		}
+	}
+	reasons := make(map[*orgRow]string, len(rows)-1)
+	for _, row := range rows {
+		if row == chosen {
+			continue
+		}
+		if _, rule := r.compare(chosen, row); rule != "" {
+			reasons[row] = orgRuleReasons[rule]
+		} else {
+			reasons[row] = reasonFirst
+		}
+	}
+	return chosen, reasons
+}
+
+// readOrgRows read rows of all csv files, in order of file names and lines
+func (c *Collect) readOrgRows() ([]*orgRow, []string, error) {
+	fnames := make([]string, 0, len(c.srcCsvFiles))
+	for fname := range c.srcCsvFiles {
+		fnames = append(fnames, fname)
+	}
+	sort.Strings(fnames)
+	rows := make([]*orgRow, 0)
+	for _, fname := range fnames {
+		csvContent, err := decodeCSV(c.srcCsvFiles[fname], c.csvEncoding(fname))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", fname, err)
+		}
+		orgs := make([]*Org, 0)
+		if err := gocsv.UnmarshalBytes(csvContent, &orgs); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", fname, err)
+		}
+		for id, org := range orgs {
+			rows = append(rows, &orgRow{Org: *org, file: fname, line: id + 2})
+		}
+	}
+	return rows, fnames, nil
+}
+
+// ReadCSV resolve org of each uid from all csv files, conflicts are kept for report
+func (c *Collect) ReadCSV() error {
+	rows, fnames, err := c.readOrgRows()
+	if err != nil {
+		return err
+	}
+	orgs := &orgTable{
+		rows:    make(map[string][]*orgRow),
+		latest:  make(map[string]string),
+		byMonth: c.conf.OrgByMonth,
+	}
+	uids := make([]string, 0)
+	for _, row := range rows {
+		if _, exist := orgs.rows[row.Uid]; !exist {
+			uids = append(uids, row.Uid)
+		}
+		orgs.rows[row.Uid] = append(orgs.rows[row.Uid], row)
+	}
+
+	// conflicts and latest are resolved by rules of config, only org as of a month puts date first
+	orgs.resolver = newOrgResolver(c.conf.OrgRules, c.conf.OrgKolTypes, c.conf.OrgFiles, fnames)
+	orgs.monthly = orgs.resolver.dateFirst()
+	c.orgConflicts = make([]*orgConflict, 0)
+	for _, uid := range uids {
+		chosen, reasons := orgs.resolver.choose(orgs.rows[uid])
+		orgs.latest[uid] = chosen.KolType
+		for _, row := range orgs.rows[uid] {
+			if row.KolType != chosen.KolType {
+				c.orgConflicts = append(c.orgConflicts, &orgConflict{uid: uid, rows: orgs.rows[uid], chosen: chosen, reasons: reasons})
+				break
+			}
+		}
+	}
+	c.orgs = orgs
+	return nil
+}
+
+// loadOrgs read csv files only once, content and mcn task share the result
+func (c *Collect) loadOrgs() (*orgTable, error) {
+	c.orgsOnce.Do(func() {
+		c.orgsErr = c.ReadCSV()
+	})
+	return c.orgs, c.orgsErr
+}
+
+func (c *Collect) orgConflictRecords() [][]string {
+	records := make([][]string, 0)
+	for _, conflict := range c.orgConflicts {
+		for _, row := range conflict.rows {
+			result := "采用"
+			if row != conflict.chosen {
+				result = "未采用"
+			}
+			records = append(records, []string{
+				conflict.uid, row.KolType, row.AddDate, row.file, strconv.Itoa(row.line), result, conflict.reasons[row],
+			})
+		}
+	}
+	return records
+}
+
+// writeOrgConflicts write every uid assigned to different orgs into "机构冲突" sheet and csv file,
+// nothing is written if no task reads csv files
+func (c *Collect) writeOrgConflicts(f *excelize.File) error {
+	if c.orgConflicts == nil {
+		return nil
+	}
+	records := c.orgConflictRecords()
+	if err := writeSheetRecords(f, "机构冲突", orgConflictHeader, records); err != nil {
+		return err
+	}
+	return writeCSVRecords(c.dstDir+"/机构冲突.csv", orgConflictHeader, records)
+}
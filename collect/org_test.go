@@ -0,0 +1,234 @@
+package collect
+
+import (
+	"excel/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOrgResolverChoose(t *testing.T) {
+	row := func(kolType, date, file string) *orgRow {
+		return &orgRow{Org: Org{KolType: kolType, AddDate: date}, file: file}
+	}
+	fnames := []string{"a.csv", "b.csv", "c.csv"}
+	tests := []struct {
+		name     string
+		rules    []string
+		kolTypes []string
+		files    []string
+		rows     []*orgRow
+		want     int      // index of row chosen
+		reasons  []string // reason of each row not chosen, empty for the chosen
+	}{
+		{
+			name: "file listed first", rules: []string{"file"}, files: []string{"c.csv"},
+			rows: []*orgRow{row("A", "", "a.csv"), row("C", "", "c.csv")},
+			want: 1, reasons: []string{reasonFile, ""},
+		},
+		{
+			name: "file listed in other case", rules: []string{"file"}, files: []string{"B.CSV"},
+			rows: []*orgRow{row("A", "", "a.csv"), row("B", "", "b.csv")},
+			want: 1, reasons: []string{reasonFile, ""},
+		},
+		{
+			name: "files not listed by name", rules: []string{"file"},
+			rows: []*orgRow{row("C", "", "c.csv"), row("A", "", "a.csv")},
+			want: 1, reasons: []string{reasonFile, ""},
+		},
+		{
+			name: "files listed before not listed", rules: []string{"file"}, files: []string{"c.csv", "missing.csv"},
+			rows: []*orgRow{row("A", "", "a.csv"), row("B", "", "b.csv"), row("C", "", "c.csv")},
+			want: 2, reasons: []string{reasonFile, reasonFile, ""},
+		},
+		{
+			name: "kol_type of star before 其他", rules: []string{"kol_type"}, kolTypes: []string{"*", "其他"},
+			rows: []*orgRow{row("其他", "", "a.csv"), row("MCN", "", "a.csv")},
+			want: 1, reasons: []string{reasonKolType, ""},
+		},
+		{
+			name: "kol_type of keyword before star", rules: []string{"kol_type"}, kolTypes: []string{"公会", "*", "其他"},
+			rows: []*orgRow{row("MCN", "", "a.csv"), row("某公会", "", "a.csv"), row("其他机构", "", "a.csv")},
+			want: 1, reasons: []string{reasonKolType, "", reasonKolType},
+		},
+		{
+			name: "kol_type without star puts others last", rules: []string{"kol_type"}, kolTypes: []string{"其他"},
+			rows: []*orgRow{row("MCN", "", "a.csv"), row("其他", "", "a.csv")},
+			want: 1, reasons: []string{reasonKolType, ""},
+		},
+		{
+			name: "kol_type of the same rank decided by date", rules: []string{"kol_type", "date"}, kolTypes: []string{"*", "其他"},
+			rows: []*orgRow{row("MCN", "2021-09-01", "a.csv"), row("公会", "2021-10-01", "a.csv")},
+			want: 1, reasons: []string{reasonDate, ""},
+		},
+		{
+			name: "date latest in any layout", rules: []string{"date"},
+			rows: []*orgRow{row("A", "2021/9/1", "a.csv"), row("B", "20211001", "a.csv"), row("C", "2021-09-15", "a.csv")},
+			want: 1, reasons: []string{reasonDate, "", reasonDate},
+		},
+		{
+			name: "date before undated and invalid", rules: []string{"date"},
+			rows: []*orgRow{row("A", "", "a.csv"), row("B", "2021.9.1", "a.csv"), row("C", "9月1日", "a.csv")},
+			want: 1, reasons: []string{reasonDate, "", reasonDate},
+		},
+		{
+			name: "undated rows decided by file", rules: []string{"date", "file"}, files: []string{"b.csv"},
+			rows: []*orgRow{row("A", "", "a.csv"), row("B", " ", "b.csv")},
+			want: 1, reasons: []string{reasonFile, ""},
+		},
+		{
+			name: "date before kol_type", rules: []string{"date", "kol_type"}, kolTypes: []string{"*", "其他"},
+			rows: []*orgRow{row("MCN", "2021-09-01", "a.csv"), row("其他", "2021-10-01", "a.csv")},
+			want: 1, reasons: []string{reasonDate, ""},
+		},
+		{
+			name: "kol_type before date", rules: []string{"kol_type", "date"}, kolTypes: []string{"*", "其他"},
+			rows: []*orgRow{row("MCN", "2021-09-01", "a.csv"), row("其他", "2021-10-01", "a.csv")},
+			want: 0, reasons: []string{"", reasonKolType},
+		},
+		{
+			name: "all rules the same", rules: []string{"kol_type", "date", "file"}, kolTypes: []string{"*", "其他"},
+			rows: []*orgRow{row("MCN甲", "2021-09-01", "a.csv"), row("MCN乙", "2021-09-01", "a.csv")},
+			want: 0, reasons: []string{"", reasonFirst},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newOrgResolver(tt.rules, tt.kolTypes, tt.files, fnames)
+			chosen, reasons := r.choose(tt.rows)
+			if chosen != tt.rows[tt.want] {
+				t.Errorf("chosen = %s, want %s", chosen.KolType, tt.rows[tt.want].KolType)
+			}
+			got := make([]string, len(tt.rows))
+			for i, row := range tt.rows {
+				got[i] = reasons[row]
+			}
+			if !reflect.DeepEqual(got, tt.reasons) {
+				t.Errorf("reasons = %q, want %q", got, tt.reasons)
+			}
+		})
+	}
+}
+
+// readTestOrgs write csv files and resolve orgs of them
+func readTestOrgs(t *testing.T, conf *config.Config, files map[string]string) *Collect {
+	t.Helper()
+	dir := t.TempDir()
+	c := NewCollect(conf)
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { f.Close() })
+		c.srcCsvFiles[name] = f
+	}
+	if err := c.ReadCSV(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+var testOrgFiles = map[string]string{
+	"a.csv": "kol_type,uid,add_date\n" +
+		"MCN甲,1,2021-09-01\n" +
+		"其他,2,2021-09-01\n" +
+		"MCN乙,3,\n",
+	"b.csv": "kol_type,uid,add_date\n" +
+		"MCN丙,1,2021-10-15\n" +
+		"MCN丁,2,2021-08-01\n" +
+		"MCN乙,3,2021-09-01\n" +
+		"MCN戊,4,2021-11-01\n",
+}
+
+func TestOrgLookup(t *testing.T) {
+	month := func(m int) period { return period{year: 2021, month: m, months: 1} }
+	tests := []struct {
+		uid     string
+		period  period
+		byMonth bool
+		want    string // empty if uid has no org
+	}{
+		// by month, rows added after the month are not used, and the latest added before wins
+		{"1", month(9), true, "MCN甲"},
+		{"1", month(10), true, "MCN丙"},
+		{"1", period{year: 2021, month: 9, months: 2}, true, "MCN丙"},
+		{"2", month(8), true, "MCN丁"},
+		{"2", month(9), true, "其他"},
+		{"3", month(1), true, "MCN乙"}, // row without add_date is always used
+		{"4", month(10), true, ""},
+		{"4", month(11), true, "MCN戊"},
+		{"5", month(11), true, ""},
+		// not by month, rules of config, kol_type before date
+		{"1", month(9), false, "MCN丙"},
+		{"2", month(8), false, "MCN丁"},
+		{"4", month(1), false, "MCN戊"},
+	}
+	for _, byMonth := range []bool{true, false} {
+		conf := &config.Config{OrgRules: []string{"kol_type", "date", "file"}, OrgKolTypes: []string{"*", "其他"}, OrgByMonth: byMonth}
+		c := readTestOrgs(t, conf, testOrgFiles)
+		for _, tt := range tests {
+			if tt.byMonth != byMonth {
+				continue
+			}
+			org, ok := c.orgs.lookup(tt.uid, tt.period)
+			if org != tt.want || ok != (tt.want != "") {
+				t.Errorf("lookup(%s, %d-%d+%d) by month %v = %q, %v, want %q", tt.uid, tt.period.year, tt.period.month,
+					tt.period.months, byMonth, org, ok, tt.want)
+			}
+		}
+	}
+}
+
+func TestOrgConflictRecords(t *testing.T) {
+	tests := []struct {
+		name string
+		conf *config.Config
+		want [][]string
+	}{
+		{
+			name: "kol_type then date",
+			conf: &config.Config{OrgRules: []string{"kol_type", "date", "file"}, OrgKolTypes: []string{"*", "其他"}},
+			want: [][]string{
+				{"1", "MCN甲", "2021-09-01", "a.csv", "2", "未采用", reasonDate},
+				{"1", "MCN丙", "2021-10-15", "b.csv", "2", "采用", ""},
+				{"2", "其他", "2021-09-01", "a.csv", "3", "未采用", reasonKolType},
+				{"2", "MCN丁", "2021-08-01", "b.csv", "3", "采用", ""},
+			},
+		},
+		{
+			name: "file listed first",
+			conf: &config.Config{OrgRules: []string{"file", "date"}, OrgFiles: []string{"a.csv"}},
+			want: [][]string{
+				{"1", "MCN甲", "2021-09-01", "a.csv", "2", "采用", ""},
+				{"1", "MCN丙", "2021-10-15", "b.csv", "2", "未采用", reasonFile},
+				{"2", "其他", "2021-09-01", "a.csv", "3", "采用", ""},
+				{"2", "MCN丁", "2021-08-01", "b.csv", "3", "未采用", reasonFile},
+			},
+		},
+		{
+			name: "date first",
+			conf: &config.Config{OrgRules: []string{"date", "kol_type", "file"}, OrgKolTypes: []string{"*", "其他"}},
+			want: [][]string{
+				{"1", "MCN甲", "2021-09-01", "a.csv", "2", "未采用", reasonDate},
+				{"1", "MCN丙", "2021-10-15", "b.csv", "2", "采用", ""},
+				{"2", "其他", "2021-09-01", "a.csv", "3", "采用", ""},
+				{"2", "MCN丁", "2021-08-01", "b.csv", "3", "未采用", reasonDate},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := readTestOrgs(t, tt.conf, testOrgFiles)
+			// uid 3 has the same org in both files, it is not a conflict
+			if got := c.orgConflictRecords(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("records = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
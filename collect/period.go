@@ -0,0 +1,205 @@
+// code for get period, namely year and month("月份"), of src file
+// period is parsed from file name by patterns of config, a workbook may cover several months such as "3-4月",
+// year is from file name, src folder name, config or current date in order if pattern has no year
+
+package collect
+
+import (
+	"errors"
+	"excel/config"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const reasonNoPeriod = "文件名中找不到月份，整个文件跳过"
+
+var errNoPeriod = errors.New("文件名中找不到月份")
+
+// period is months covered by src file
+type period struct {
+	year, month int // the first month
+	months      int // number of months, one for most workbooks
+}
+
+// value get value of month cell, the first day of the first month
+func (p period) value() time.Time {
+	return time.Date(p.year, time.Month(p.month), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// numFmt get number format of month cell, such as "2022年3月" or "2022年3-4月"
+func (p period) numFmt() string {
+	if p.months <= 1 {
+		return `yyyy"年"m"月"`
+	}
+	last := (p.month+p.months-2)%12 + 1
+	return `yyyy"年"m"-` + strconv.Itoa(last) + `月"`
+}
+
+// end get the first day after the last month
+func (p period) end() time.Time {
+	return time.Date(p.year, time.Month(p.month+max(p.months, 1)), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// key get number to sort periods
+func (p period) key() int {
+	return p.year*100 + p.month
+}
+
+var yearReg = regexp.MustCompile(`(?:^|\D)((?:19|20)\d{2})(?:\D|$)`)
+
+// yearFromName get four-digit year from name of file or folder, false if not found
+func yearFromName(name string) (int, bool) {
+	found := yearReg.FindStringSubmatch(name)
+	if found == nil {
+		return 0, false
+	}
+	year, err := strconv.Atoi(found[1])
+	return year, err == nil
+}
+
+// fileYear get year of src file whose name has no year in pattern
+func (c *Collect) fileYear(fname string, month int) int {
+	if year, ok := yearFromName(fname); ok {
+		return year
+	}
+	if dir, err := filepath.Abs(c.srcDir); err == nil {
+		if year, ok := yearFromName(filepath.Base(dir)); ok {
+			return year
+		}
+	}
+	if c.conf.Year > 0 {
+		return c.conf.Year
+	}
+	// month later than now is of last year, such as december workbook processed in january
+	now := time.Now()
+	if month > int(now.Month()) {
+		return now.Year() - 1
+	}
+	return now.Year()
+}
+
+var cnNumbers = map[rune]int{'一': 1, '二': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9, '十': 10}
+
+// parseNumber parse arabic or chinese number up to twenty, such as "03", "三" or "十二"
+func parseNumber(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, nil
+	}
+	n := 0
+	for i, r := range []rune(s) {
+		v, ok := cnNumbers[r]
+		if !ok {
+			return 0, fmt.Errorf("无法识别的数字“%s”", s)
+		}
+		if v == 10 {
+			if i == 0 {
+				n = 10 // "十" or "十二"
+			} else {
+				n *= 10 // "二十"
+			}
+		} else {
+			n += v
+		}
+	}
+	return n, nil
+}
+
+var rangeSepReg = regexp.MustCompile(`[-~～至到]`)
+
+// parsePeriod parse period from file name by patterns, errNoPeriod if no pattern matches
+func (c *Collect) parsePeriod(fname string, patterns []*regexp.Regexp) (period, error) {
+	name := strings.TrimSuffix(fname, filepath.Ext(fname))
+	for _, pattern := range patterns {
+		found := pattern.FindStringSubmatch(name)
+		if found == nil {
+			continue
+		}
+		group := func(name string) string {
+			if i := pattern.SubexpIndex(name); i >= 0 {
+				return found[i]
+			}
+			return ""
+		}
+
+		p := period{months: 1}
+		var err error
+		if text := group(config.PeriodRange); text != "" {
+			ends := rangeSepReg.Split(text, 2)
+			if len(ends) != 2 {
+				return p, fmt.Errorf("无法识别的月份范围“%s”", text)
+			}
+			var last int
+			if p.month, err = parseNumber(ends[0]); err != nil {
+				return p, err
+			}
+			if last, err = parseNumber(ends[1]); err != nil {
+				return p, err
+			}
+			p.months = (last-p.month+12)%12 + 1 // such as "11-2月" across years
+		} else if text := group(config.PeriodQuarter); text != "" {
+			quarter, err := parseNumber(text)
+			if err != nil {
+				return p, err
+			}
+			if quarter < 1 || quarter > 4 {
+				return p, fmt.Errorf("季度%d无效", quarter)
+			}
+			p.month, p.months = quarter*3-2, 3
+		} else if p.month, err = parseNumber(group(config.PeriodMonth)); err != nil {
+			return p, err
+		}
+		if p.month < 1 || p.month > 12 {
+			return p, fmt.Errorf("月份%d无效", p.month)
+		}
+
+		if text := group(config.PeriodYear); text != "" {
+			if p.year, err = strconv.Atoi(text); err != nil {
+				return p, fmt.Errorf("无法识别的年份“%s”", text)
+			}
+			if p.year < 100 {
+				p.year += 2000 // such as "22年"
+			}
+		} else {
+			p.year = c.fileYear(fname, p.month)
+		}
+		return p, nil
+	}
+	return period{}, errNoPeriod
+}
+
+// loadPeriods get period of each src file, conf.Period overrides all files
+func (c *Collect) loadPeriods() {
+	for _, fname := range c.srcNames {
+		if c.conf.Period != "" {
+			if t, err := time.Parse("2006-01", c.conf.Period); err == nil {
+				c.srcPeriods[fname] = period{year: t.Year(), month: int(t.Month()), months: 1}
+				continue
+			}
+		}
+		p, err := c.parsePeriod(fname, c.conf.PeriodPatterns)
+		if err != nil {
+			c.srcPeriodErrs[fname] = err
+			continue
+		}
+		c.srcPeriods[fname] = p
+	}
+}
+
+// srcPeriod get period of src file for task, false if the file is skipped,
+// file whose period can not be parsed is an error or skipped by conf.PeriodUnmatched
+func (c *Collect) srcPeriod(task, fname string) (period, bool, error) {
+	err, failed := c.srcPeriodErrs[fname]
+	if !failed {
+		return c.srcPeriods[fname], true, nil
+	}
+	if errors.Is(err, errNoPeriod) && c.conf.PeriodUnmatched == "skip" {
+		c.report.Add(ReportEntry{Task: task, File: fname, Reason: reasonNoPeriod})
+		return period{}, false, nil
+	}
+	return period{}, false, &TaskError{Task: task, File: fname, Err: err}
+}
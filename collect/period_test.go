@@ -0,0 +1,80 @@
+package collect
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+	conf := testConfig(t)
+	conf.Year = 2021 // year of file name without year, src folder has none
+	c := NewCollect(conf)
+	custom := []*regexp.Regexp{regexp.MustCompile(`(?P<year>\d{2})年(?P<month>\d{1,2})月`)}
+	tests := []struct {
+		fname    string
+		patterns []*regexp.Regexp // nil for default patterns
+		want     period
+		err      string // part of error, empty if parsed
+	}{
+		{fname: "2022年3月费用.xlsx", want: period{2022, 3, 1}},
+		{fname: "费用2022-03.xlsx", want: period{2022, 3, 1}},
+		{fname: "2022年3-4月费用.xlsx", want: period{2022, 3, 2}},
+		{fname: "11-2月费用.xlsx", want: period{2021, 11, 4}}, // across years
+		{fname: "3月费用.xlsx", want: period{2021, 3, 1}},
+		{fname: "2020费用3月.xlsx", want: period{2020, 3, 1}}, // year from name out of pattern
+		{fname: "三至四月费用.xlsx", want: period{2021, 3, 2}},
+		{fname: "十二月费用.xlsx", want: period{2021, 12, 1}},
+		{fname: "2022Q2费用.xlsx", want: period{2022, 4, 3}},
+		{fname: "第四季度费用.xlsx", want: period{2021, 10, 3}},
+		{fname: "22年3月费用.xlsx", patterns: custom, want: period{2022, 3, 1}},
+		{fname: "2021年13月费用.xlsx", err: "月份13无效"},
+		{fname: "2021年0-2月费用.xlsx", err: "月份0无效"},
+		{fname: "费用.xlsx", err: errNoPeriod.Error()},
+		{fname: "3月费用.xlsx", patterns: custom, err: errNoPeriod.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fname, func(t *testing.T) {
+			patterns := tt.patterns
+			if patterns == nil {
+				patterns = conf.PeriodPatterns
+			}
+			got, err := c.parsePeriod(tt.fname, patterns)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("parsePeriod = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+	if _, err := c.parsePeriod("费用.xlsx", conf.PeriodPatterns); !errors.Is(err, errNoPeriod) {
+		t.Errorf("err = %v, want errNoPeriod", err)
+	}
+}
+
+func TestPeriodMonths(t *testing.T) {
+	tests := []struct {
+		p      period
+		numFmt string
+		end    time.Time
+	}{
+		{period{2022, 3, 1}, `yyyy"年"m"月"`, time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{period{2022, 3, 2}, `yyyy"年"m"-4月"`, time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{period{2021, 11, 4}, `yyyy"年"m"-2月"`, time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{period{2021, 12, 0}, `yyyy"年"m"月"`, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := tt.p.numFmt(); got != tt.numFmt {
+			t.Errorf("numFmt of %+v = %s, want %s", tt.p, got, tt.numFmt)
+		}
+		if got := tt.p.end(); !got.Equal(tt.end) {
+			t.Errorf("end of %+v = %s, want %s", tt.p, got, tt.end)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// code for read src sheets by several readers and write them into dst by one writer
+// src sheets are written in the order they are given, whatever the number of readers
+
+package collect
+
+import (
+	"context"
+	"errors"
+	"github.com/xuri/excelize/v2"
+	"sync"
+)
+
+// bookWriter serialize all writes of one dst file in its own goroutine,
+// because excelize.File is not safe for concurrent use
+type bookWriter struct {
+	file *excelize.File
+	jobs chan bookJob
+	done chan struct{}
+}
+
+type bookJob struct {
+	fn   func(f *excelize.File) error
+	errc chan error
+}
+
+func newBookWriter(f *excelize.File) *bookWriter {
+	w := &bookWriter{
+		file: f,
+		jobs: make(chan bookJob),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(w.done)
+		for job := range w.jobs {
+			job.errc <- job.fn(w.file)
+		}
+	}()
+	return w
+}
+
+// Do run fn in writer goroutine and wait for it
+func (w *bookWriter) Do(fn func(f *excelize.File) error) error {
+	errc := make(chan error, 1)
+	w.jobs <- bookJob{fn: fn, errc: errc}
+	return <-errc
+}
+
+// Close stop writer goroutine after all jobs are done
+func (w *bookWriter) Close() {
+	close(w.jobs)
+	<-w.done
+}
+
+type readResult struct {
+	index int
+	sheet *Sheet
+	err   error
+}
+
+// readers return number of reader goroutines for each task
+func (c *Collect) readers() int {
+	if !c.conf.Concurrent || c.conf.Readers <= 0 {
+		return 1
+	}
+	return c.conf.Readers
+}
+
+// pipeline read sheets by readers and write them into target by dst writer in order of sheets,
+// sheets of the same src file must be next to each other, a file with a sheet failed to read is skipped
+// and the error is returned with others after all written
+func (c *Collect) pipeline(ctx context.Context, sheets []*Sheet, read func(*Sheet) error,
+	target *Sheet, write func(from *Sheet) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan int)
+	results := make(chan readResult)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < c.readers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				sheet := sheets[index]
+				sheet.fileMutex.Lock() // tasks may read the same src file at the same time
+				err := read(sheet)
+				sheet.fileMutex.Unlock()
+				select {
+				case results <- readResult{index: index, sheet: sheet, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for index := range sheets {
+			select {
+			case jobs <- index:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	stats := c.stats[target.task]
+	for _, sheet := range sheets {
+		if n := len(stats.Files); n == 0 || stats.Files[n-1] != sheet.fileName {
+			stats.Files = append(stats.Files, sheet.fileName)
+		}
+	}
+
+	// write in order of sheets, keep the results read ahead until their turn
+	writeSheet := func(sheet *Sheet) error {
+		err := c.dstWriters[target.fileName].Do(func(f *excelize.File) error {
+			return write(sheet)
+		})
+		if err != nil {
+			return &TaskError{Task: target.task, File: target.fileName, Sheet: target.name, Err: err}
+		}
+		stats.RowsWritten += len(sheet.data)
+		return nil
+	}
+	var runErr RunError
+	sorting := len(c.conf.RowOrder) != 0 // rows are written after all read and sorted
+	done := make([]*Sheet, 0, len(sheets))
+	// sheets of one src file are written together after all of them are read, and none of them if one fails,
+	// so a file left out of manifest has nothing in dst file and is collected again by next append run
+	group := make([]*Sheet, 0)
+	groupFailed := false
+	flush := func() error {
+		defer func() { group, groupFailed = group[:0], false }()
+		if groupFailed {
+			return nil
+		}
+		for _, sheet := range group {
+			if sorting {
+				done = append(done, sheet)
+				continue
+			}
+			if err := writeSheet(sheet); err != nil {
+				return err
+			}
+			sheet.data = nil // written, release memory
+		}
+		return nil
+	}
+	pending := make(map[int]readResult)
+	next := 0
+	for result := range results {
+		pending[result.index] = result
+		for {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if len(group) != 0 && group[0].fileName != result.sheet.fileName {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			group = append(group, result.sheet)
+			if result.err != nil {
+				err := result.err
+				var taskErr *TaskError
+				if !errors.As(err, &taskErr) {
+					err = result.sheet.taskErr(result.sheet.name, err) // file must be known to manifest
+				}
+				runErr = runErr.add(result.sheet.task, err)
+				groupFailed = true
+				continue
+			}
+			stats.RowsRead += len(result.sheet.data)
+		}
+	}
+	if err := ctx.Err(); err != nil && next < len(sheets) {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if sorting {
+		for _, row := range sortRows(done, c.conf.RowOrder) {
+			if err := writeSheet(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	return runErr.sorted()
+}
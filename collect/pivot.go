@@ -0,0 +1,455 @@
+// code for pivot tables and charts declared in config, built on sheets of dst file and updated in every run
+// pivot tables are filled by excel when the file is opened, charts are drawn from a table of sums beside them
+// sheets are cleared instead of deleted, and parts of existing pivot tables and charts are rewritten in place,
+// as excelize numbers a new part by count of existing ones, and parts of deleted sheets would be left behind
+
+package collect
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"excel/config"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const (
+	relTypePivotTable = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/pivotTable"
+	relTypePivotCache = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/pivotCacheDefinition"
+	relTypeDrawing    = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/drawing"
+	relTypeChart      = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/chart"
+
+	// parts of the only pivot table and chart of a new file
+	newPivotTablePart = "xl/pivotTables/pivotTable1.xml"
+	newPivotCachePart = "xl/pivotCache/pivotCacheDefinition1.xml"
+	newChartPart      = "xl/charts/chart1.xml"
+	newDrawingPart    = "xl/drawings/drawing1.xml"
+
+	// reasons of pivot table or chart not built
+	reasonNoSource = "找不到源工作表，跳过"
+	reasonNoHeader = "源工作表第一行没有表头，跳过"
+	reasonNoField  = "源工作表表头找不到列：%s，跳过"
+	reasonNoData   = "源工作表没有数据，跳过"
+	reasonNoSeries = "源工作表表头找不到列：%s，不画这些系列"
+)
+
+// attrs of the root element kept when a part is rewritten
+var (
+	cacheIDReg = regexp.MustCompile(`\scacheId="[^"]*"`)
+	nameReg    = regexp.MustCompile(`\sname="[^"]*"`)
+)
+
+// names of data fields in pivot table, such as "求和项:视频费用"
+var subtotalPrefixes = map[string]string{
+	"Sum":     "求和项:",
+	"Count":   "计数项:",
+	"Average": "平均值项:",
+	"Max":     "最大值项:",
+	"Min":     "最小值项:",
+}
+
+// sourceRows get header and data rows of source sheet, reason is not empty if it can not be used,
+// header is the first row, and a blank cell in it ends the header
+func sourceRows(f *excelize.File, sheet string) ([]string, [][]string, string, error) {
+	if f.GetSheetIndex(sheet) == -1 {
+		return nil, nil, reasonNoSource, nil
+	}
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if len(rows) == 0 {
+		return nil, nil, reasonNoHeader, nil
+	}
+	header := make([]string, 0, len(rows[0]))
+	for _, colData := range rows[0] {
+		if strings.TrimSpace(colData) == "" {
+			break
+		}
+		header = append(header, strings.TrimSpace(colData))
+	}
+	if len(header) == 0 {
+		return nil, nil, reasonNoHeader, nil
+	}
+	if len(rows) < 2 {
+		return header, nil, reasonNoData, nil
+	}
+	return header, rows[1:], "", nil
+}
+
+// missingFields get names not found in header
+func missingFields(header []string, names ...[]string) []string {
+	missing := make([]string, 0)
+	for _, group := range names {
+		for _, name := range group {
+			if indexOf(header, name) == -1 {
+				missing = append(missing, name)
+			}
+		}
+	}
+	return missing
+}
+
+// clearSheet remove all rows of sheet, or create it if not exists, the sheet keeps its tables,
+// pivot tables and charts
+func clearSheet(f *excelize.File, sheet string) error {
+	if f.GetSheetIndex(sheet) == -1 {
+		f.NewSheet(sheet)
+		return nil
+	}
+	rows, err := f.Rows(sheet)
+	if err != nil {
+		return err
+	}
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	// rows below are moved up by each removal
+	for i := 0; i < count; i++ {
+		if err := f.RemoveRow(sheet, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newParts build on a new file, and get content of parts of it
+func newParts(build func(f *excelize.File) error, names ...string) (map[string][]byte, error) {
+	f := excelize.NewFile()
+	if err := build(f); err != nil {
+		return nil, err
+	}
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		return nil, err
+	}
+	parts := make(map[string][]byte, len(names))
+	for _, file := range zr.File {
+		if indexOf(names, file.Name) == -1 {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		parts[file.Name] = content
+	}
+	for _, name := range names {
+		if _, ok := parts[name]; !ok {
+			return nil, fmt.Errorf("%s not found in new file", name)
+		}
+	}
+	return parts, nil
+}
+
+// rootTag get start tag of the root element of xml part
+func rootTag(content []byte) []byte {
+	for start := 0; start < len(content); start++ {
+		next := bytes.IndexByte(content[start:], '<')
+		if next == -1 {
+			return nil
+		}
+		start += next
+		if start+1 < len(content) && content[start+1] != '?' && content[start+1] != '!' {
+			if end := bytes.IndexByte(content[start:], '>'); end != -1 {
+				return content[start : start+end+1]
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// keepAttr set attr of reg in root element of content to the one of old
+func keepAttr(content, old []byte, reg *regexp.Regexp) []byte {
+	oldAttr, tag := reg.Find(rootTag(old)), rootTag(content)
+	if oldAttr == nil || !reg.Match(tag) {
+		return content
+	}
+	return bytes.Replace(content, tag, reg.ReplaceAllLiteral(tag, oldAttr), 1)
+}
+
+func (c *Collect) skipReport(kind, sheet, reason string) {
+	fmt.Println(kind+"[", sheet, "]:", reason)
+	c.report.Add(ReportEntry{Task: kind, File: "项目立项及实际费用明细.xlsx", Sheet: sheet, Reason: reason})
+}
+
+// writePivots write pivot tables of config into dst file
+func (c *Collect) writePivots(f *excelize.File) error {
+	for _, pivot := range c.conf.Pivots {
+		header, rows, reason, err := sourceRows(f, pivot.Source)
+		if err != nil {
+			return err
+		} else if reason != "" {
+			c.skipReport("pivot", pivot.Sheet, reason)
+			continue
+		}
+		if missing := missingFields(header, pivot.Rows, pivot.Columns, pivot.Data, pivot.Filter); len(missing) != 0 {
+			c.skipReport("pivot", pivot.Sheet, fmt.Sprintf(reasonNoField, strings.Join(missing, "、")))
+			continue
+		}
+		if err := writePivot(f, pivot, header, len(rows)+1); err != nil {
+			return fmt.Errorf("%s: %w", pivot.Sheet, err)
+		}
+	}
+	return nil
+}
+
+// writePivot add pivot table on source range of cols and rows, header included,
+// pivot table of sheet made in an earlier run is rewritten in place, with its name and cache
+func writePivot(f *excelize.File, pivot *config.Pivot, header []string, rows int) error {
+	if err := clearSheet(f, pivot.Sheet); err != nil {
+		return err
+	}
+	tables, err := sheetTargets(f, pivot.Sheet, relTypePivotTable)
+	if err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		return f.AddPivotTable(pivotOption(pivot, len(header), rows))
+	}
+	caches, err := partTargets(f, tables[0].Target, relTypePivotCache)
+	if err != nil {
+		return err
+	}
+	old, ok := f.Pkg.Load(tables[0].Target)
+	if !ok || len(caches) == 0 {
+		return fmt.Errorf("%s: pivot cache not found", tables[0].Target)
+	}
+
+	// the same pivot table on a new file, only header of source is read
+	parts, err := newParts(func(nf *excelize.File) error {
+		nf.NewSheet(pivot.Source)
+		if err := nf.SetSheetRow(pivot.Source, "A1", &header); err != nil {
+			return err
+		}
+		nf.NewSheet(pivot.Sheet)
+		return nf.AddPivotTable(pivotOption(pivot, len(header), rows))
+	}, newPivotTablePart, newPivotCachePart)
+	if err != nil {
+		return err
+	}
+	table := keepAttr(parts[newPivotTablePart], old.([]byte), cacheIDReg)
+	f.Pkg.Store(tables[0].Target, keepAttr(table, old.([]byte), nameReg))
+	// records saved by excel do not match the new cache, it is filled when the file is opened
+	cache := caches[0].Target
+	f.Pkg.Store(cache, parts[newPivotCachePart])
+	f.Pkg.Delete(relsPath(cache))
+	f.Relationships.Delete(relsPath(cache))
+	return nil
+}
+
+// pivotOption get option of pivot table on source range of cols and rows
+func pivotOption(pivot *config.Pivot, cols, rows int) *excelize.PivotTableOption {
+	fields := func(names []string) []excelize.PivotTableField {
+		fields := make([]excelize.PivotTableField, 0, len(names))
+		for _, name := range names {
+			fields = append(fields, excelize.PivotTableField{Data: name})
+		}
+		return fields
+	}
+	data := make([]excelize.PivotTableField, 0, len(pivot.Data))
+	for _, name := range pivot.Data {
+		data = append(data, excelize.PivotTableField{Data: name, Name: subtotalPrefixes[pivot.Subtotal] + name, Subtotal: pivot.Subtotal})
+	}
+	lastCol, _ := excelize.ColumnNumberToName(cols)
+	// rows above are left for filter fields
+	return &excelize.PivotTableOption{
+		DataRange:           fmt.Sprintf("%s!$A$1:$%s$%d", pivot.Source, lastCol, rows),
+		PivotTableRange:     fmt.Sprintf("%s!$A$3:$%s$%d", pivot.Sheet, lastCol, rows+3),
+		Rows:                fields(pivot.Rows),
+		Columns:             fields(pivot.Columns),
+		Data:                data,
+		Filter:              fields(pivot.Filter),
+		RowGrandTotals:      true,
+		ColGrandTotals:      true,
+		ShowDrill:           true,
+		ShowRowHeaders:      true,
+		ShowColHeaders:      true,
+		ShowLastColumn:      true,
+		PivotTableStyleName: "PivotStyleLight16",
+	}
+}
+
+// writeCharts write charts of config into dst file, each with a table of sums by category
+func (c *Collect) writeCharts(f *excelize.File) error {
+	for _, chart := range c.conf.Charts {
+		header, rows, reason, err := sourceRows(f, chart.Source)
+		if err != nil {
+			return err
+		} else if reason != "" {
+			c.skipReport("chart", chart.Sheet, reason)
+			continue
+		}
+		// series of columns not in source are dropped, such as task not enabled in rollup sheet
+		if missing := missingFields(header, []string{chart.Category}); len(missing) != 0 {
+			c.skipReport("chart", chart.Sheet, fmt.Sprintf(reasonNoField, strings.Join(missing, "、")))
+			continue
+		}
+		series := make([]string, 0, len(chart.Series))
+		for _, name := range chart.Series {
+			if indexOf(header, name) != -1 {
+				series = append(series, name)
+			}
+		}
+		if missing := missingFields(header, chart.Series); len(series) == 0 {
+			c.skipReport("chart", chart.Sheet, fmt.Sprintf(reasonNoField, strings.Join(missing, "、")))
+			continue
+		} else if len(missing) != 0 {
+			c.report.Add(ReportEntry{Task: "chart", File: "项目立项及实际费用明细.xlsx", Sheet: chart.Sheet,
+				Reason: fmt.Sprintf(reasonNoSeries, strings.Join(missing, "、")), Kept: true})
+		}
+
+		// sums of series in order of categories first appear, total row of rollup is not a category
+		categories := make([]string, 0)
+		sums := make(map[string][]float64)
+		catCol := indexOf(header, chart.Category)
+		for _, row := range rows {
+			category := ""
+			if catCol < len(row) {
+				category = strings.TrimSpace(row[catCol])
+			}
+			if category == "" || category == "合计" {
+				continue
+			}
+			if _, ok := sums[category]; !ok {
+				categories = append(categories, category)
+				sums[category] = make([]float64, len(series))
+			}
+			for i, name := range series {
+				if col := indexOf(header, name); col < len(row) {
+					if value, err := parseQuantity(row[col]); err == nil {
+						sums[category][i] += value
+					}
+				}
+			}
+		}
+		if len(categories) == 0 {
+			c.skipReport("chart", chart.Sheet, reasonNoData)
+			continue
+		}
+		if err := writeChart(f, chart, series, categories, sums); err != nil {
+			return fmt.Errorf("%s: %w", chart.Sheet, err)
+		}
+	}
+	return nil
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// writeChart write table of sums of series at A1 and the chart on the right of it
+func writeChart(f *excelize.File, chart *config.Chart, series []string, categories []string, sums map[string][]float64) error {
+	if err := clearSheet(f, chart.Sheet); err != nil {
+		return err
+	}
+	header := append([]string{chart.Category}, series...)
+	if err := f.SetSheetRow(chart.Sheet, "A1", &header); err != nil {
+		return err
+	}
+	for i, category := range categories {
+		values := []interface{}{category}
+		for _, sum := range sums[category] {
+			values = append(values, sum)
+		}
+		axis, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := f.SetSheetRow(chart.Sheet, axis, &values); err != nil {
+			return err
+		}
+	}
+	amountStyle, err := f.NewStyle(&excelize.Style{NumFmt: numFmtAmount})
+	if err != nil {
+		return err
+	}
+	lastCol, _ := excelize.ColumnNumberToName(len(header))
+	lastRow := len(categories) + 1
+	if err := f.SetCellStyle(chart.Sheet, "B2", fmt.Sprintf("%s%d", lastCol, lastRow), amountStyle); err != nil {
+		return err
+	}
+
+	sheet := "'" + strings.ReplaceAll(chart.Sheet, "'", "''") + "'"
+	refs := make([]map[string]string, 0, len(series))
+	for i := range series {
+		col, _ := excelize.ColumnNumberToName(i + 2)
+		refs = append(refs, map[string]string{
+			"name":       fmt.Sprintf("%s!$%s$1", sheet, col),
+			"categories": fmt.Sprintf("%s!$A$2:$A$%d", sheet, lastRow),
+			"values":     fmt.Sprintf("%s!$%s$2:$%s$%d", sheet, col, col, lastRow),
+		})
+	}
+	title := chart.Title
+	if title == "" {
+		title = chart.Sheet
+	}
+	format, err := json.Marshal(map[string]interface{}{
+		"type":      chart.Type,
+		"series":    refs,
+		"title":     map[string]string{"name": title},
+		"legend":    map[string]string{"position": "bottom"},
+		"dimension": map[string]int{"width": 720, "height": 400},
+	})
+	if err != nil {
+		return err
+	}
+	axis, _ := excelize.CoordinatesToCellName(len(header)+2, 1)
+	return setChart(f, chart.Sheet, axis, string(format))
+}
+
+// setChart add chart at axis of sheet, chart of sheet made in an earlier run is rewritten in place,
+// with the drawing holding it
+func setChart(f *excelize.File, sheet, axis, format string) error {
+	drawings, err := sheetTargets(f, sheet, relTypeDrawing)
+	if err != nil {
+		return err
+	} else if len(drawings) == 0 {
+		return f.AddChart(sheet, axis, format)
+	}
+	drawing := drawings[0].Target
+	charts, err := partTargets(f, drawing, relTypeChart)
+	if err != nil {
+		return err
+	} else if len(charts) == 0 {
+		return f.AddChart(sheet, axis, format)
+	}
+
+	// the same chart on a new file, the drawing refers to it by id of rel in new file
+	var newRels []partRel
+	parts, err := newParts(func(nf *excelize.File) error {
+		nf.NewSheet(sheet)
+		if err := nf.AddChart(sheet, axis, format); err != nil {
+			return err
+		}
+		newRels, err = partTargets(nf, newDrawingPart, relTypeChart)
+		return err
+	}, newChartPart, newDrawingPart)
+	if err != nil {
+		return err
+	} else if len(newRels) == 0 {
+		return fmt.Errorf("%s: chart not found in new file", sheet)
+	}
+	f.Pkg.Store(charts[0].Target, parts[newChartPart])
+	f.Pkg.Store(drawing, bytes.ReplaceAll(parts[newDrawingPart],
+		[]byte(`r:id="`+newRels[0].ID+`"`), []byte(`r:id="`+charts[0].ID+`"`)))
+	f.Drawings.Delete(drawing) // parsed drawing would be saved over it
+	return nil
+}
@@ -0,0 +1,182 @@
+// code for record each src row which is not carried over into dst, or carried over with problems
+// report is written into "校验报告" sheet of dst file and a standalone csv file
+
+package collect
+
+import (
+	"encoding/csv"
+	"github.com/xuri/excelize/v2"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+const (
+	// reasons of skipped row
+	reasonHidden    = "隐藏工作表，整表跳过"
+	reasonNoAnchor  = "找不到表头，整表跳过"
+	reasonSkip      = "辅助行"
+	reasonNotEnough = "关键列为空"
+	reasonEnd       = "数据结束"
+	reasonAfterEnd  = "位于数据结束之后"
+)
+
+var reportHeader = []string{"任务", "文件", "工作表", "行号", "原因", "处理", "原始数据"}
+
+const (
+	// how the row is handled, column 处理 of report
+	actionKept    = "已保留"
+	actionSkipped = "已跳过"
+)
+
+type ReportEntry struct {
+	Task   string
+	File   string
+	Sheet  string
+	Row    int // row number start from one, zero for whole sheet
+	Reason string
+	Values []string // raw data of this row
+	Kept   bool     // row is carried over in spite of the problem
+}
+
+// Report is safe for concurrent use by tasks
+type Report struct {
+	mu      sync.Mutex
+	entries []ReportEntry
+}
+
+func NewReport() *Report {
+	return &Report{entries: make([]ReportEntry, 0)}
+}
+
+func (r *Report) Add(entry ReportEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+}
+
+// Entries return all entries sorted by task, file, sheet and row
+func (r *Report) Entries() []ReportEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := make([]ReportEntry, len(r.entries))
+	copy(entries, r.entries)
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Task != b.Task {
+			return a.Task < b.Task
+		} else if a.File != b.File {
+			return a.File < b.File
+		} else if a.Sheet != b.Sheet {
+			return a.Sheet < b.Sheet
+		}
+		return a.Row < b.Row
+	})
+	return entries
+}
+
+// Count return number of src rows of task not carried over, whole sheets and files not included,
+// rows carried over with problems are not counted
+func (r *Report) Count(task string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for i := range r.entries {
+		if r.entries[i].Task == task && r.entries[i].Row > 0 && !r.entries[i].Kept {
+			count++
+		}
+	}
+	return count
+}
+
+func (e *ReportEntry) record() []string {
+	row := ""
+	if e.Row > 0 {
+		row = strconv.Itoa(e.Row)
+	}
+	action := actionSkipped
+	if e.Kept {
+		action = actionKept
+	}
+	return append([]string{e.Task, e.File, e.Sheet, row, e.Reason, action}, e.Values...)
+}
+
+// WriteSheet write all entries into sheet of f, old sheet with the same name is replaced
+func (r *Report) WriteSheet(f *excelize.File, sheetName string) error {
+	return writeSheetRecords(f, sheetName, reportHeader, r.records())
+}
+
+// WriteCSV write all entries into csv file, with utf-8 bom for excel
+func (r *Report) WriteCSV(path string) error {
+	return writeCSVRecords(path, reportHeader, r.records())
+}
+
+// writeSheetRecords write header and records into sheet of f, old sheet with the same name is replaced
+func writeSheetRecords(f *excelize.File, sheetName string, header []string, records [][]string) error {
+	if f.GetSheetIndex(sheetName) != -1 {
+		f.DeleteSheet(sheetName)
+	}
+	f.NewSheet(sheetName)
+	for row, record := range append([][]string{header}, records...) {
+		axis, _ := excelize.CoordinatesToCellName(1, row+1)
+		if err := f.SetSheetRow(sheetName, axis, &record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCSVRecords write header and records into csv file, with utf-8 bom for excel
+func writeCSVRecords(path string, header []string, records [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.WriteString("\xEF\xBB\xBF"); err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	if err := w.WriteAll(records); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (r *Report) records() [][]string {
+	entries := r.Entries()
+	records := make([][]string, 0, len(entries))
+	for i := range entries {
+		records = append(records, entries[i].record())
+	}
+	return records
+}
+
+// skipRow record a src row which is not carried over, blank row is ignored
+func (s *Sheet) skipRow(sheetName string, row int, reason string, colsData []string) {
+	if s.report == nil {
+		return
+	}
+	blank := true
+	for _, colData := range colsData {
+		if colData != "" {
+			blank = false
+			break
+		}
+	}
+	if blank && row > 0 {
+		return
+	}
+	s.report.Add(ReportEntry{
+		Task:   s.task,
+		File:   s.fileName,
+		Sheet:  sheetName,
+		Row:    row,
+		Reason: reason,
+		Values: colsData,
+	})
+}
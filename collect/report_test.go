@@ -0,0 +1,60 @@
+package collect
+
+import (
+	"encoding/csv"
+	"github.com/xuri/excelize/v2"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReportRecords(t *testing.T) {
+	r := NewReport()
+	r.Add(ReportEntry{Task: "mcn", File: "b.xlsx", Sheet: "MCN", Row: 3, Reason: reasonNotEnough, Values: []string{"", "x"}})
+	r.Add(ReportEntry{Task: "mcn", File: "a.xlsx", Sheet: "MCN", Reason: reasonHidden})
+	r.Add(ReportEntry{Task: "cps", File: "a.xlsx", Sheet: "CPS", Row: 2, Reason: "金额无法解析", Values: []string{"abc"}, Kept: true})
+	want := [][]string{
+		reportHeader,
+		{"cps", "a.xlsx", "CPS", "2", "金额无法解析", actionKept, "abc"},
+		{"mcn", "a.xlsx", "MCN", "", reasonHidden, actionSkipped},
+		{"mcn", "b.xlsx", "MCN", "3", reasonNotEnough, actionSkipped, "", "x"},
+	}
+	if r.Count("mcn") != 1 || r.Count("cps") != 0 {
+		t.Errorf("count = %d, %d, want 1 of mcn and 0 of cps", r.Count("mcn"), r.Count("cps"))
+	}
+
+	path := filepath.Join(t.TempDir(), "校验报告.csv")
+	if err := r.WriteCSV(path); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.Seek(3, 0); err != nil { // utf-8 bom
+		t.Fatal(err)
+	}
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("csv = %q, want %q", records, want)
+	}
+
+	f := excelize.NewFile()
+	if err := r.WriteSheet(f, "校验报告"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := f.GetRows("校验报告")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("sheet = %q, want %q", rows, want)
+	}
+}
@@ -0,0 +1,378 @@
+// code for rollup sheets built from the collected detail sheets, updated in every run
+// "汇总" is spend of each detail sheet by month, department and game, "内容费用汇总" splits content spend
+// totals are SUMIFS formulas pointing back at detail sheets, so they stay correct after manual edits
+
+package collect
+
+import (
+	"excel/config"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	rollupSheet        = "汇总"
+	contentRollupSheet = "内容费用汇总"
+	reasonNoRollup     = "找不到月份、运营部门、游戏产品或金额列，不参与汇总"
+)
+
+// fields of detail sheets used by rollup, month is "startDate" for sheets without month
+var (
+	rollupMoneyFields   = []string{"money", videoMoneyD, textMoneyD, unclsMoneyD}
+	contentSplitFields  = []string{videoMoneyD, textMoneyD, unclsMoneyD}
+	contentSplitHeaders = map[string]string{videoMoneyD: "视频费用", textMoneyD: "图文费用", unclsMoneyD: "不能区分"}
+)
+
+// month cell rendered by excelize, such as "2022年3月" or "2022年3-0月" for range
+var monthCellReg = regexp.MustCompile(`^(\d{4})年(\d{1,2})`)
+
+// rollupSource is a detail sheet and its columns
+type rollupSource struct {
+	sheet       string
+	cols        map[string]string                // field, col letter
+	month       string                           // col letter of month
+	money       []string                         // col letters to sum
+	headerRow   int                              // rows before it are not data
+	sums        map[rollupKey]map[string]float64 // combo of month, department and game, col letter, sum
+	contentCols map[string]string                // field, col letter of content split
+}
+
+// rollupColumn is a column of rollup sheet, value is cached in cell for apps not calculating formulas
+type rollupColumn struct {
+	header  string
+	formula func(row int) string
+	value   func(key rollupKey) float64
+}
+
+type rollupKey struct {
+	month      time.Time
+	department string
+	game       string
+}
+
+// rollupCol find dst col letter of field, by dst letter of schema or header row of sheet
+func rollupCol(schema *config.Schema, field string, header []string) (string, bool) {
+	column := schema.Column(field)
+	if column == nil {
+		return "", false
+	} else if hasDst(schema) {
+		return column.Dst, column.Dst != ""
+	}
+	for id, colData := range header {
+		if colData != "" && matchHeader(colData, column) {
+			colName, _ := excelize.ColumnNumberToName(id + 1)
+			return colName, true
+		}
+	}
+	return "", false
+}
+
+// cellMonth get the first day of month from month or date cell rendered as text
+func cellMonth(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if found := monthCellReg.FindStringSubmatch(value); found != nil {
+		year, _ := strconv.Atoi(found[1])
+		month, _ := strconv.Atoi(found[2])
+		if month >= 1 && month <= 12 {
+			return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC), true
+		}
+	}
+	for _, layout := range []string{"2006/1/2", "01-02-06", "2006-01-02", "2006.1.2", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), true
+		}
+	}
+	if serial, err := strconv.ParseFloat(value, 64); err == nil && serial > 0 && serial < 2958466 {
+		if t, err := excelize.ExcelDateToTime(serial, false); err == nil {
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// colIndex get index of col letter in row
+func colIndex(col string) int {
+	n, _ := excelize.ColumnNameToNumber(col)
+	return n - 1
+}
+
+func cellOf(row []string, col string) string {
+	if i := colIndex(col); i < len(row) {
+		return strings.TrimSpace(row[i])
+	}
+	return ""
+}
+
+// rollupSources find detail sheets of tasks in f, and sums of combos of month, department and game in them
+func (c *Collect) rollupSources(f *excelize.File) ([]*rollupSource, error) {
+	sources := make([]*rollupSource, 0)
+	for _, task := range c.schemaTasks() {
+		schema, ok := c.conf.Schema[task]
+		if !ok || f.GetSheetIndex(schema.Target) == -1 {
+			continue
+		}
+		rows, err := f.GetRows(schema.Target)
+		if err != nil {
+			return nil, err
+		}
+		source := &rollupSource{sheet: schema.Target, cols: make(map[string]string), headerRow: 1, sums: make(map[rollupKey]map[string]float64)}
+		var header []string
+		if len(rows) > 0 && !hasDst(schema) {
+			header = rows[0]
+		}
+		for _, column := range schema.Columns {
+			if col, ok := rollupCol(schema, column.Field, header); ok {
+				source.cols[column.Field] = col
+			}
+		}
+		if col, ok := source.cols[monthD]; ok {
+			source.month = col
+		} else if col, ok := source.cols[startDate]; ok {
+			source.month = col
+		}
+		for _, field := range rollupMoneyFields {
+			if col, ok := source.cols[field]; ok {
+				source.money = append(source.money, col)
+			}
+		}
+		_, okDepartment := source.cols[department]
+		_, okGame := source.cols[game]
+		if source.month == "" || len(source.money) == 0 || !okDepartment || !okGame {
+			c.report.Add(ReportEntry{Task: task, File: "项目立项及实际费用明细.xlsx", Sheet: schema.Target, Reason: reasonNoRollup})
+			continue
+		}
+		if task == "content" {
+			source.contentCols = make(map[string]string)
+			for _, field := range contentSplitFields {
+				if col, ok := source.cols[field]; ok {
+					source.contentCols[field] = col
+				}
+			}
+		}
+
+		for i, row := range rows {
+			if i < source.headerRow {
+				continue
+			}
+			month, ok := cellMonth(cellOf(row, source.month))
+			if !ok {
+				continue
+			}
+			key := rollupKey{month, cellOf(row, source.cols[department]), cellOf(row, source.cols[game])}
+			if source.sums[key] == nil {
+				source.sums[key] = make(map[string]float64)
+			}
+			for _, col := range source.money {
+				if money, err := parseQuantity(cellOf(row, col)); err == nil {
+					source.sums[key][col] += money
+				}
+			}
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// schemaTasks get tasks of schema in taskOrder, unknown tasks are sorted at the end
+func (c *Collect) schemaTasks() []string {
+	tasks := make([]string, 0, len(c.conf.Schema))
+	unknown := make([]string, 0)
+	for task := range c.conf.Schema {
+		known := false
+		for _, t := range taskOrder {
+			if t == task {
+				known = true
+				break
+			}
+		}
+		if !known {
+			unknown = append(unknown, task)
+		}
+	}
+	for _, task := range taskOrder {
+		if _, ok := c.conf.Schema[task]; ok {
+			tasks = append(tasks, task)
+		}
+	}
+	sort.Strings(unknown)
+	return append(tasks, unknown...)
+}
+
+// sortedKeys get combos of sources sorted by month, department and game
+func sortedKeys(sources []*rollupSource) []rollupKey {
+	all := make(map[rollupKey]bool)
+	for _, source := range sources {
+		for key := range source.sums {
+			all[key] = true
+		}
+	}
+	keys := make([]rollupKey, 0, len(all))
+	for key := range all {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if !a.month.Equal(b.month) {
+			return a.month.Before(b.month)
+		} else if a.department != b.department {
+			return a.department < b.department
+		}
+		return a.game < b.game
+	})
+	return keys
+}
+
+// sumifs get formula to sum col of source in month of A, department of B and game of C in row
+func (source *rollupSource) sumifs(col string, row int) string {
+	ref := func(col string) string {
+		return fmt.Sprintf("'%s'!$%s:$%s", strings.ReplaceAll(source.sheet, "'", "''"), col, col)
+	}
+	month := ref(source.month)
+	return fmt.Sprintf(`SUMIFS(%s,%s,">="&$A%d,%s,"<"&DATE(YEAR($A%d),MONTH($A%d)+1,1),%s,$B%d&"",%s,$C%d&"")`,
+		ref(col), month, row, month, row, row, ref(source.cols[department]), row, ref(source.cols[game]), row)
+}
+
+// writeRollup write rollup sheet of columns, each row is a combo of keys, the last column and row are totals
+func writeRollup(f *excelize.File, sheetName string, keys []rollupKey, columns []rollupColumn) error {
+	// sheet is cleared instead of deleted, its table is kept for formulas using it
+	if err := clearSheet(f, sheetName); err != nil {
+		return err
+	}
+	header := []string{"月份", "运营部门", "游戏产品"}
+	for _, column := range columns {
+		header = append(header, column.header)
+	}
+	header = append(header, "合计")
+	if err := f.SetSheetRow(sheetName, "A1", &header); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	exp := `yyyy"年"m"月"`
+	monthStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &exp})
+	if err != nil {
+		return err
+	}
+	amountStyle, err := f.NewStyle(&excelize.Style{NumFmt: numFmtAmount})
+	if err != nil {
+		return err
+	}
+	// setSum set cached value before formula, excelize keeps the value
+	setSum := func(col, row int, value float64, formula string) error {
+		axis, _ := excelize.CoordinatesToCellName(col, row)
+		if err := f.SetCellFloat(sheetName, axis, value, 2, 64); err != nil {
+			return err
+		}
+		return f.SetCellFormula(sheetName, axis, formula)
+	}
+	firstCol, _ := excelize.ColumnNumberToName(4)
+	lastCol, _ := excelize.ColumnNumberToName(3 + len(columns))
+	totalCol, _ := excelize.ColumnNumberToName(4 + len(columns))
+	totals := make([]float64, len(columns)+1)
+	for i, key := range keys {
+		row := i + 2
+		values := []interface{}{key.month, key.department, key.game}
+		axis, _ := excelize.CoordinatesToCellName(1, row)
+		if err := f.SetSheetRow(sheetName, axis, &values); err != nil {
+			return err
+		}
+		if err := f.SetCellStyle(sheetName, axis, axis, monthStyle); err != nil {
+			return err
+		}
+		rowTotal := 0.0
+		for col, column := range columns {
+			value := column.value(key)
+			rowTotal += value
+			totals[col] += value
+			if err := setSum(4+col, row, value, column.formula(row)); err != nil {
+				return err
+			}
+		}
+		totals[len(columns)] += rowTotal
+		if err := setSum(4+len(columns), row, rowTotal, fmt.Sprintf("SUM(%s%d:%s%d)", firstCol, row, lastCol, row)); err != nil {
+			return err
+		}
+	}
+
+	// total of all rows
+	totalRow := len(keys) + 2
+	if err := f.SetCellValue(sheetName, fmt.Sprintf("A%d", totalRow), "合计"); err != nil {
+		return err
+	}
+	for col, total := range totals {
+		colName, _ := excelize.ColumnNumberToName(4 + col)
+		if err := setSum(4+col, totalRow, total, fmt.Sprintf("SUM(%s2:%s%d)", colName, colName, totalRow-1)); err != nil {
+			return err
+		}
+	}
+	return f.SetCellStyle(sheetName, firstCol+"2", fmt.Sprintf("%s%d", totalCol, totalRow), amountStyle)
+}
+
+// writeRollups write rollup sheets into dst file, formulas are calculated again when the file is opened
+func (c *Collect) writeRollups(f *excelize.File) error {
+	if !c.conf.Rollup {
+		return nil
+	}
+	sources, err := c.rollupSources(f)
+	if err != nil {
+		return err
+	}
+	columns := make([]rollupColumn, 0, len(sources))
+	for _, source := range sources {
+		source := source
+		columns = append(columns, rollupColumn{
+			header: source.sheet,
+			formula: func(row int) string {
+				sums := make([]string, 0, len(source.money))
+				for _, col := range source.money {
+					sums = append(sums, source.sumifs(col, row))
+				}
+				return strings.Join(sums, "+")
+			},
+			value: func(key rollupKey) float64 {
+				sum := 0.0
+				for _, col := range source.money {
+					sum += source.sums[key][col]
+				}
+				return sum
+			},
+		})
+	}
+	if err := writeRollup(f, rollupSheet, sortedKeys(sources), columns); err != nil {
+		return err
+	}
+
+	for _, source := range sources {
+		source := source
+		if source.contentCols == nil {
+			continue
+		}
+		columns := make([]rollupColumn, 0, len(contentSplitFields))
+		for _, field := range contentSplitFields {
+			col, ok := source.contentCols[field]
+			if !ok {
+				continue
+			}
+			columns = append(columns, rollupColumn{
+				header:  contentSplitHeaders[field],
+				formula: func(row int) string { return source.sumifs(col, row) },
+				value:   func(key rollupKey) float64 { return source.sums[key][col] },
+			})
+		}
+		if err := writeRollup(f, contentRollupSheet, sortedKeys([]*rollupSource{source}), columns); err != nil {
+			return err
+		}
+	}
+
+	// excelize does not calculate SUMIFS, let excel and libreoffice calculate all when opened
+	if f.WorkBook != nil && f.WorkBook.CalcPr != nil {
+		f.WorkBook.CalcPr.FullCalcOnLoad = true
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package collect
+
+import (
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSumifs(t *testing.T) {
+	tests := []struct {
+		sheet string
+		col   string
+		row   int
+		want  string
+	}{
+		{
+			"活动", "F", 2,
+			`SUMIFS('活动'!$F:$F,'活动'!$D:$D,">="&$A2,'活动'!$D:$D,"<"&DATE(YEAR($A2),MONTH($A2)+1,1),` +
+				`'活动'!$B:$B,$B2&"",'活动'!$C:$C,$C2&"")`,
+		},
+		{
+			"O'Neil费用", "AA", 15,
+			`SUMIFS('O''Neil费用'!$AA:$AA,'O''Neil费用'!$D:$D,">="&$A15,'O''Neil费用'!$D:$D,"<"&DATE(YEAR($A15),MONTH($A15)+1,1),` +
+				`'O''Neil费用'!$B:$B,$B15&"",'O''Neil费用'!$C:$C,$C15&"")`,
+		},
+	}
+	for _, tt := range tests {
+		source := &rollupSource{sheet: tt.sheet, month: "D", cols: map[string]string{department: "B", game: "C"}}
+		if got := source.sumifs(tt.col, tt.row); got != tt.want {
+			t.Errorf("sumifs of %s = %s, want %s", tt.sheet, got, tt.want)
+		}
+	}
+}
+
+func TestCellMonth(t *testing.T) {
+	month := func(year, month int) time.Time { return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		value string
+		want  time.Time
+		ok    bool
+	}{
+		{"2022年3月", month(2022, 3), true},
+		{"2022年3-4月", month(2022, 3), true},
+		{"2022/3/15", month(2022, 3), true},
+		{"03-15-22", month(2022, 3), true},
+		{"2022-03-15", month(2022, 3), true},
+		{"20220315", month(2022, 3), true},
+		{"44635", month(2022, 3), true}, // serial of 2022-03-15
+		{"2022年13月", time.Time{}, false},
+		{"合计", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := cellMonth(tt.value)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("cellMonth(%q) = %s, %v, want %s, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestWriteRollup check formulas and cached values of rollup sheet, also when it is written again with fewer rows
+func TestWriteRollup(t *testing.T) {
+	keys := []rollupKey{
+		{time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC), "部门1", "游戏1"},
+		{time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC), "部门2", "游戏2"},
+	}
+	sources := []*rollupSource{
+		{sheet: "活动", month: "D", money: []string{"F"}, cols: map[string]string{department: "B", game: "C"},
+			sums: map[rollupKey]map[string]float64{keys[0]: {"F": 100}, keys[1]: {"F": 200.5}}},
+		{sheet: "CPS分发", month: "D", money: []string{"F", "G"}, cols: map[string]string{department: "B", game: "C"},
+			sums: map[rollupKey]map[string]float64{keys[1]: {"F": 10, "G": 20}}},
+	}
+	columns := make([]rollupColumn, 0, len(sources))
+	for _, source := range sources {
+		source := source
+		columns = append(columns, rollupColumn{
+			header: source.sheet,
+			formula: func(row int) string {
+				sums := make([]string, 0, len(source.money))
+				for _, col := range source.money {
+					sums = append(sums, source.sumifs(col, row))
+				}
+				return strings.Join(sums, "+")
+			},
+			value: func(key rollupKey) float64 {
+				sum := 0.0
+				for _, col := range source.money {
+					sum += source.sums[key][col]
+				}
+				return sum
+			},
+		})
+	}
+
+	f := excelize.NewFile()
+	for _, keys := range [][]rollupKey{keys, keys[:1]} {
+		if err := writeRollup(f, rollupSheet, keys, columns); err != nil {
+			t.Fatal(err)
+		}
+		totalRow := len(keys) + 2
+		formulas := [][2]string{
+			{"D2", sources[0].sumifs("F", 2)},
+			{"E2", sources[1].sumifs("F", 2) + "+" + sources[1].sumifs("G", 2)},
+			{"F2", "SUM(D2:E2)"},
+			{fmt.Sprintf("D%d", totalRow), fmt.Sprintf("SUM(D2:D%d)", totalRow-1)},
+			{fmt.Sprintf("F%d", totalRow), fmt.Sprintf("SUM(F2:F%d)", totalRow-1)},
+		}
+		for _, formula := range formulas {
+			if got, err := f.GetCellFormula(rollupSheet, formula[0]); err != nil || got != formula[1] {
+				t.Errorf("%d keys: formula of %s = %q, %v, want %q", len(keys), formula[0], got, err, formula[1])
+			}
+		}
+
+		rows, err := f.GetRows(rollupSheet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := [][]string{
+			{"月份", "运营部门", "游戏产品", "活动", "CPS分发", "合计"},
+			{"2021年9月", "部门1", "游戏1", "100.00", "0.00", "100.00"},
+			{"2021年10月", "部门2", "游戏2", "200.50", "30.00", "230.50"},
+			{"合计", "", "", "300.50", "30.00", "330.50"},
+		}
+		if len(keys) == 1 {
+			want = [][]string{want[0], want[1], {"合计", "", "", "100.00", "0.00", "100.00"}}
+		}
+		if !reflect.DeepEqual(rows, want) {
+			t.Errorf("%d keys: rows = %q, want %q", len(keys), rows, want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+// code for drive Sheet by column mapping schema, see config.Schema
+
+package collect
+
+import (
+	"excel/config"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"sort"
+	"strings"
+)
+
+const reasonDupOptional = "可选列%s匹配到多列：%s，不取该列"
+
+// matchSheet check whether src sheet name contains keyword s.name but none of excluded words
+func (s *Sheet) matchSheet(sheetName string) bool {
+	if !strings.Contains(strings.ToUpper(sheetName), strings.ToUpper(s.name)) {
+		return false
+	}
+	for _, exclude := range s.schema.Exclude {
+		if strings.Contains(sheetName, exclude) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchHeader(colData string, column *config.Column) bool {
+	matched := false
+	for _, header := range column.Headers() {
+		if strings.Contains(colData, header) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+	for _, exclude := range column.Exclude {
+		if strings.Contains(colData, exclude) {
+			return false
+		}
+	}
+	return true
+}
+
+// findCols find src col index of each schema field by header row
+func (s *Sheet) findCols(sheetName string, header []string) error {
+	s.cols = make(map[string]int)
+	headerErr := &HeaderError{
+		File:       s.fileName,
+		Sheet:      sheetName,
+		Row:        s.row,
+		Duplicated: make(map[string][]string),
+	}
+	for i := range s.schema.Columns {
+		column := &s.schema.Columns[i]
+		if column.Header == "" {
+			continue
+		}
+		found := make([]string, 0, 1)
+		for id, colData := range header {
+			if matchHeader(colData, column) {
+				s.cols[column.Field] = id
+				colName, _ := excelize.ColumnNumberToName(id + 1)
+				found = append(found, colName)
+			}
+		}
+		if len(found) == 0 && !column.Optional {
+			headerErr.Missing = append(headerErr.Missing, column.Header)
+		} else if len(found) > 1 && column.Optional {
+			// such as 预算金额 and 结算金额 for 金额, the field is left out instead of guessing
+			delete(s.cols, column.Field)
+			s.reportDupOptional(sheetName, column.Header, found, header)
+		} else if len(found) > 1 {
+			headerErr.Duplicated[column.Header] = found
+		}
+	}
+	if len(headerErr.Missing) != 0 || len(headerErr.Duplicated) != 0 {
+		return headerErr
+	}
+	return nil
+}
+
+func (s *Sheet) reportDupOptional(sheetName, header string, found []string, colsData []string) {
+	if s.report == nil {
+		return
+	}
+	s.report.Add(ReportEntry{
+		Task:   s.task,
+		File:   s.fileName,
+		Sheet:  sheetName,
+		Row:    s.row,
+		Reason: fmt.Sprintf(reasonDupOptional, header, strings.Join(found, "、")),
+		Values: colsData,
+		Kept:   true,
+	})
+}
+
+// dstCols get dst col number of each schema field which has dst letter
+func dstCols(schema *config.Schema) (map[string]int, error) {
+	cols := make(map[string]int)
+	for _, column := range schema.Columns {
+		if column.Dst == "" {
+			continue
+		}
+		col, err := excelize.ColumnNameToNumber(column.Dst)
+		if err != nil {
+			return nil, err
+		}
+		cols[column.Field] = col
+	}
+	return cols, nil
+}
+
+// hasDst check whether dst cols of task are fixed by schema, or the same as src with header in the first row
+func hasDst(schema *config.Schema) bool {
+	for _, column := range schema.Columns {
+		if column.Dst != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// dstTitle get title of dst col, header or field if empty
+func dstTitle(column *config.Column) string {
+	if column.Title != "" {
+		return column.Title
+	} else if column.Header != "" {
+		return column.Header
+	}
+	return column.Field
+}
+
+// writeDstHeader write titles of dst cols into blank cells of the first row of target sheet, if dst cols are
+// fixed by schema, such as new sheet or sheet written before dst header is supported; an existing sheet in other
+// cols is refused instead of appended to: titles in the first row must match, or without titles, data must be
+// only in dst cols
+func writeDstHeader(f *excelize.File, schema *config.Schema) error {
+	if !hasDst(schema) {
+		return nil
+	}
+	rows, err := f.GetRows(schema.Target)
+	if err != nil {
+		return err
+	}
+	var header []string
+	if len(rows) > 0 {
+		header = rows[0]
+	}
+	titles := make(map[int]string) // col index start from zero, title
+	for i := range schema.Columns {
+		column := &schema.Columns[i]
+		if column.Dst == "" {
+			continue
+		}
+		col, err := excelize.ColumnNameToNumber(column.Dst)
+		if err != nil {
+			return err
+		}
+		titles[col-1] = dstTitle(column)
+	}
+
+	layoutErr := &LayoutError{Sheet: schema.Target}
+	if strings.TrimSpace(strings.Join(header, "")) != "" {
+		for col, colData := range header {
+			colData = strings.TrimSpace(colData)
+			if title, ok := titles[col]; ok && colData != "" && colData != title {
+				colName, _ := excelize.ColumnNumberToName(col + 1)
+				layoutErr.Mismatch = append(layoutErr.Mismatch, fmt.Sprintf("%s1是“%s”而不是“%s”", colName, colData, title))
+			}
+		}
+	} else if len(rows) > 1 {
+		outside := make(map[int]bool)
+		for _, row := range rows[1:] {
+			for col, colData := range row {
+				if _, ok := titles[col]; !ok && strings.TrimSpace(colData) != "" {
+					outside[col] = true
+				}
+			}
+		}
+		cols := make([]int, 0, len(outside))
+		for col := range outside {
+			cols = append(cols, col)
+		}
+		sort.Ints(cols)
+		for _, col := range cols {
+			colName, _ := excelize.ColumnNumberToName(col + 1)
+			layoutErr.Outside = append(layoutErr.Outside, colName)
+		}
+	}
+	if len(layoutErr.Mismatch) != 0 || len(layoutErr.Outside) != 0 {
+		return layoutErr
+	}
+
+	for col, title := range titles {
+		if col < len(header) && strings.TrimSpace(header[col]) != "" {
+			continue
+		}
+		colName, _ := excelize.ColumnNumberToName(col + 1)
+		if err := f.SetCellStr(schema.Target, colName+"1", title); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// colValue get data of src field, empty if field or data not exist
+func (s *Sheet) colValue(colsData []string, field string) string {
+	col, ok := s.cols[field]
+	if !ok || col >= len(colsData) {
+		return ""
+	}
+	return colsData[col]
+}
+
+// checkRequired check values of required fields found in header row, by names instead of positions,
+// blank is true if all of them are empty, which is the end of data, partial is true if some of them are empty
+func (s *Sheet) checkRequired(colsData []string) (blank, partial bool) {
+	checked, empty := 0, 0
+	for _, field := range s.schema.Required() {
+		if _, ok := s.cols[field]; !ok {
+			continue // optional field not found
+		}
+		checked++
+		if strings.TrimSpace(s.colValue(colsData, field)) == "" {
+			empty++
+		}
+	}
+	return checked > 0 && empty == checked, empty > 0 && empty < checked
+}
+
+// setCell set value to dst field of current row, do nothing if field has no dst col
+func (s *Sheet) setCell(field string, value interface{}) error {
+	col, ok := s.dst[field]
+	if !ok {
+		return nil
+	}
+	dstAxis, _ := excelize.CoordinatesToCellName(col, s.row)
+	return s.file.SetCellValue(s.name, dstAxis, value)
+}
+
+// setCellStyle set style to dst field of current row, do nothing if field has no dst col
+func (s *Sheet) setCellStyle(field string, style int) error {
+	col, ok := s.dst[field]
+	if !ok {
+		return nil
+	}
+	dstAxis, _ := excelize.CoordinatesToCellName(col, s.row)
+	return s.file.SetCellStyle(s.name, dstAxis, dstAxis, style)
+}
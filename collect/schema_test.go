@@ -0,0 +1,41 @@
+package collect
+
+import (
+	"excel/config"
+	"testing"
+)
+
+func TestCheckRequired(t *testing.T) {
+	schema := &config.Schema{Columns: []config.Column{
+		{Field: "uid", Header: "UID", Required: true},
+		{Field: "nickName", Header: "昵称", Required: true},
+		{Field: "agency", Header: "MCN机构", Optional: true, Required: true},
+		{Field: "money", Header: "金额", Required: true},
+		{Field: "game", Header: "游戏产品"},
+	}}
+	tests := []struct {
+		name           string
+		header         []string
+		row            []string
+		blank, partial bool
+	}{
+		{"all filled", []string{"UID", "昵称", "金额", "游戏产品"}, []string{"1", "a", "100", "g"}, false, false},
+		{"reordered with extra cols", []string{"备注", "金额", "游戏产品", "昵称", "UID"}, []string{"", "100", "", "a", "1"}, false, false},
+		{"one empty", []string{"备注", "金额", "游戏产品", "昵称", "UID"}, []string{"x", "100", "g", "", "1"}, false, true},
+		{"short row", []string{"UID", "昵称", "游戏产品", "备注", "金额"}, []string{"1", "a", "g"}, false, true},
+		{"all empty with others", []string{"UID", "游戏产品", "昵称", "金额"}, []string{" ", "g", "", ""}, true, false},
+		{"optional found", []string{"MCN机构", "UID", "昵称", "金额", "游戏产品"}, []string{"", "1", "a", "100"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Sheet{schema: schema}
+			if err := s.findCols("Sheet1", tt.header); err != nil {
+				t.Fatal(err)
+			}
+			blank, partial := s.checkRequired(tt.row)
+			if blank != tt.blank || partial != tt.partial {
+				t.Errorf("checkRequired(%q) = %v, %v, want %v, %v", tt.row, blank, partial, tt.blank, tt.partial)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+// code for typed values, amount and count columns are written as numbers instead of text
+// value can not be parsed is written as it is and recorded in report
+
+package collect
+
+import (
+	"errors"
+	"excel/config"
+	"github.com/xuri/excelize/v2"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const reasonBadValue = "数值无法识别，按文本写入"
+
+// built-in number formats of excel
+const (
+	numFmtAmount = 4 // #,##0.00
+	numFmtCount  = 3 // #,##0
+)
+
+var errBadValue = errors.New("not a number")
+
+// units of number, such as "1.2万" and "3.4w"
+var numberUnits = []struct {
+	suffix string
+	times  float64
+}{
+	{"亿", 1e8},
+	{"万", 1e4},
+	{"w", 1e4},
+	{"W", 1e4},
+	{"千", 1e3},
+	{"k", 1e3},
+	{"K", 1e3},
+}
+
+// numberReplacer remove currency symbols and thousands separators
+var numberReplacer = strings.NewReplacer(
+	",", "", "，", "", " ", "", " ", "",
+	"¥", "", "￥", "", "$", "", "RMB", "", "CNY", "",
+)
+
+// parseQuantity parse number with currency symbol, thousands separator and unit, such as "¥1,234.50元" or "1.2万"
+func parseQuantity(raw string) (float64, error) {
+	s := numberReplacer.Replace(strings.TrimSpace(raw))
+	s = strings.TrimSuffix(s, "元")
+	times := 1.0
+	for _, unit := range numberUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSuffix(s, unit.suffix)
+			times = unit.times
+			break
+		}
+	}
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		s = s[1 : len(s)-1] // (100) in accounting is -100
+		negative = true
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, errBadValue
+	}
+	if negative {
+		v = -v
+	}
+	return v * times, nil
+}
+
+// parseValue parse raw value by type, text is returned as it is
+func parseValue(typ, raw string) (interface{}, error) {
+	switch typ {
+	case config.TypeAmount:
+		v, err := parseQuantity(raw)
+		if err != nil {
+			return raw, err
+		}
+		return math.Round(v*100) / 100, nil
+	case config.TypeCount:
+		v, err := parseQuantity(raw)
+		if err != nil {
+			return raw, err
+		}
+		return int64(math.Round(v)), nil
+	}
+	return raw, nil
+}
+
+// numFmt get number format of type, zero for text
+func numFmt(typ string) int {
+	switch typ {
+	case config.TypeAmount:
+		return numFmtAmount
+	case config.TypeCount:
+		return numFmtCount
+	}
+	return 0
+}
+
+// fieldType get value type of src field
+func (s *Sheet) fieldType(field string) string {
+	if column := s.schema.Column(field); column != nil {
+		return column.Type
+	}
+	return ""
+}
+
+// checkValues record typed values of src row which can not be parsed, the row is still carried over
+func (s *Sheet) checkValues(sheetName string, row int, colsData []string) {
+	for _, column := range s.schema.Columns {
+		raw := s.colValue(colsData, column.Field)
+		if raw == "" {
+			continue
+		}
+		if _, err := parseValue(column.Type, raw); err != nil {
+			s.reportValue(sheetName, row, column.Header, colsData)
+		}
+	}
+}
+
+func (s *Sheet) reportValue(sheetName string, row int, header string, colsData []string) {
+	if s.report == nil {
+		return
+	}
+	s.report.Add(ReportEntry{
+		Task:   s.task,
+		File:   s.fileName,
+		Sheet:  sheetName,
+		Row:    row,
+		Reason: reasonBadValue + "：" + header,
+		Values: colsData,
+		Kept:   true,
+	})
+}
+
+// numStyle get style of number format in dst file, styles are created once for each target sheet
+func (s *Sheet) numStyle(typ string) (int, error) {
+	if style, ok := s.styles[typ]; ok {
+		return style, nil
+	}
+	style, err := s.file.NewStyle(&excelize.Style{NumFmt: numFmt(typ)})
+	if err != nil {
+		return 0, err
+	}
+	if s.styles == nil {
+		s.styles = make(map[string]int)
+	}
+	s.styles[typ] = style
+	return style, nil
+}
+
+// setValue set raw value of type to dst field of current row, as number if it can be parsed
+func (s *Sheet) setValue(field, typ, raw string) error {
+	value, err := parseValue(typ, raw)
+	if err != nil || numFmt(typ) == 0 {
+		return s.setCell(field, value) // reported when read
+	}
+	if err := s.setCell(field, value); err != nil {
+		return err
+	}
+	style, err := s.numStyle(typ)
+	if err != nil {
+		return err
+	}
+	return s.setCellStyle(field, style)
+}
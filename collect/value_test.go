@@ -0,0 +1,75 @@
+package collect
+
+import (
+	"errors"
+	"excel/config"
+	"testing"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want float64
+		err  bool
+	}{
+		{"1234.5", 1234.5, false},
+		{" 1,234.50 ", 1234.5, false},
+		{"¥1,234.50元", 1234.5, false},
+		{"￥1，234", 1234, false},
+		{"RMB 100", 100, false},
+		{"1.2万", 12000, false},
+		{"3.4w", 34000, false},
+		{"2亿", 2e8, false},
+		{"1.5k", 1500, false},
+		{"(100)", -100, false},
+		{"-50", -50, false},
+		{"1e3", 1000, false},
+		{"", 0, true},
+		{"abc", 0, true},
+		{"12元3角", 0, true},
+		{"NaN", 0, true},
+		{"Inf", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseQuantity(tt.raw)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parseQuantity(%q) = %v, %v, want %v, error %v", tt.raw, got, err, tt.want, tt.err)
+		}
+		if err != nil && !errors.Is(err, errBadValue) {
+			t.Errorf("parseQuantity(%q) error = %v, want errBadValue", tt.raw, err)
+		}
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		typ  string
+		raw  string
+		want interface{}
+		err  bool
+	}{
+		// amount is rounded to 2 decimals, half away from zero
+		{config.TypeAmount, "1.234", 1.23, false},
+		{config.TypeAmount, "1.236", 1.24, false},
+		{config.TypeAmount, "0.125", 0.13, false},
+		{config.TypeAmount, "(1,234.567)", -1234.57, false},
+		{config.TypeAmount, "1.2万", 12000.0, false},
+		{config.TypeAmount, "100", 100.0, false},
+		// count is rounded to integer
+		{config.TypeCount, "1.2万", int64(12000), false},
+		{config.TypeCount, "2.5", int64(3), false},
+		{config.TypeCount, "1,234", int64(1234), false},
+		// value can not be parsed is kept as it is
+		{config.TypeAmount, "待定", "待定", true},
+		{config.TypeCount, "N/A", "N/A", true},
+		// text, also of empty type, is not parsed
+		{config.TypeText, "1,234", "1,234", false},
+		{"", "待定", "待定", false},
+	}
+	for _, tt := range tests {
+		got, err := parseValue(tt.typ, tt.raw)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parseValue(%q, %q) = %#v, %v, want %#v, error %v", tt.typ, tt.raw, got, err, tt.want, tt.err)
+		}
+	}
+}
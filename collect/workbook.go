@@ -0,0 +1,185 @@
+// code for reading src workbooks of different formats in the same way,
+// xlsx is read by excelize, legacy xls (BIFF8) by package xls and ods by package ods
+
+package collect
+
+import (
+	"bytes"
+	"errors"
+	"excel/ods"
+	"excel/xls"
+	"github.com/xuri/excelize/v2"
+)
+
+var errUnknownFormat = errors.New("未知的文件格式，只支持xlsx、ods和Excel 97-2003 xls")
+
+// magic bytes at the beginning of file
+var (
+	zipMagic = []byte("PK\x03\x04")
+	cfbMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+)
+
+// workbook is src file read by sheets
+type workbook interface {
+	GetSheetList() []string
+	GetSheetVisible(sheet string) bool
+	SearchSheet(sheet, value string, reg ...bool) ([]string, error)
+	Rows(sheet string) (rowIterator, error)
+	NumFmt(sheet string, col, row int) int // number format id of cell, col and row start from one
+}
+
+// rowIterator iterate rows of sheet, blank row is nil
+type rowIterator interface {
+	Next() bool
+	Columns() ([]string, error)
+}
+
+// openWorkbook open src workbook by content, not by file name,
+// some "xls" files are xlsx or html actually
+func openWorkbook(content []byte) (workbook, error) {
+	switch {
+	case bytes.HasPrefix(content, zipMagic) && ods.Is(content):
+		wb, err := ods.OpenBytes(content)
+		if err != nil {
+			return nil, err
+		}
+		return newOdsBook(wb), nil
+	case bytes.HasPrefix(content, zipMagic):
+		f, err := excelize.OpenReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		return xlsxBook{f}, nil
+	case bytes.HasPrefix(content, cfbMagic):
+		wb, err := xls.OpenBytes(content)
+		if err != nil {
+			return nil, err
+		}
+		return newXlsBook(wb), nil
+	}
+	return nil, errUnknownFormat
+}
+
+type xlsxBook struct {
+	*excelize.File
+}
+
+func (b xlsxBook) Rows(sheet string) (rowIterator, error) {
+	return b.File.Rows(sheet)
+}
+
+func (b xlsxBook) NumFmt(sheet string, col, row int) int {
+	coords, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		return 0
+	}
+	style, err := b.GetCellStyle(sheet, coords)
+	if err != nil || style == 0 || b.Styles == nil || b.Styles.CellXfs == nil {
+		return 0
+	}
+	if style >= len(b.Styles.CellXfs.Xf) || b.Styles.CellXfs.Xf[style].NumFmtID == nil {
+		return 0
+	}
+	return *b.Styles.CellXfs.Xf[style].NumFmtID
+}
+
+// gridBook is workbook whose cells are all read into memory, such as xls and ods
+type gridBook struct {
+	sheets []*gridSheet
+}
+
+type gridSheet struct {
+	name    string
+	visible bool
+	rows    [][]string             // blank row is nil
+	numFmt  func(col, row int) int // nil if number format is unknown
+}
+
+func newXlsBook(wb *xls.Workbook) gridBook {
+	var b gridBook
+	for _, sheet := range wb.Sheets() {
+		b.sheets = append(b.sheets, &gridSheet{name: sheet.Name, visible: sheet.Visible, rows: sheet.Rows(), numFmt: sheet.NumFmt})
+	}
+	return b
+}
+
+func newOdsBook(wb *ods.Workbook) gridBook {
+	var b gridBook
+	for _, sheet := range wb.Sheets() {
+		b.sheets = append(b.sheets, &gridSheet{name: sheet.Name, visible: sheet.Visible, rows: sheet.Rows()})
+	}
+	return b
+}
+
+func (b gridBook) sheet(name string) (*gridSheet, error) {
+	for _, s := range b.sheets {
+		if s.name == name {
+			return s, nil
+		}
+	}
+	return nil, errors.New("sheet " + name + " is not exist")
+}
+
+func (b gridBook) GetSheetList() []string {
+	names := make([]string, 0, len(b.sheets))
+	for _, s := range b.sheets {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+func (b gridBook) GetSheetVisible(sheet string) bool {
+	s, err := b.sheet(sheet)
+	return err == nil && s.visible
+}
+
+// SearchSheet find cells of value the same as excelize, regexp is not supported
+func (b gridBook) SearchSheet(sheet, value string, reg ...bool) ([]string, error) {
+	s, err := b.sheet(sheet)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for row, cols := range s.rows {
+		for col, cell := range cols {
+			if cell == value {
+				axis, _ := excelize.CoordinatesToCellName(col+1, row+1)
+				result = append(result, axis)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Rows iterate copies of rows, readers may change the values
+func (b gridBook) Rows(sheet string) (rowIterator, error) {
+	s, err := b.sheet(sheet)
+	if err != nil {
+		return nil, err
+	}
+	return &gridRows{rows: s.rows, cur: -1}, nil
+}
+
+func (b gridBook) NumFmt(sheet string, col, row int) int {
+	if s, err := b.sheet(sheet); err == nil && s.numFmt != nil {
+		return s.numFmt(col, row)
+	}
+	return 0
+}
+
+type gridRows struct {
+	rows [][]string
+	cur  int
+}
+
+func (r *gridRows) Next() bool {
+	r.cur++
+	return r.cur < len(r.rows)
+}
+
+func (r *gridRows) Columns() ([]string, error) {
+	if r.rows[r.cur] == nil {
+		return nil, nil
+	}
+	return append([]string(nil), r.rows[r.cur]...), nil
+}
@@ -1,48 +1,199 @@
 package config
 
 import (
+	"fmt"
 	"github.com/spf13/viper"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
 )
 
 type Config struct {
 	Concurrent       bool
+	Workers          int // max tasks run at the same time, zero means all
+	Readers          int // reader goroutines of src files for each task
 	TaskMap          map[string]bool
 	SrcPath, DstPath string
+	FileOrder        string             // order of src files, "name", "month" or "mtime"
+	RowOrder         []string           // fields to sort rows before written, empty means src order
+	Append           bool               // append new src files into existing dst file instead of overwrite
+	Ods              bool               // also write dst file as ods beside xlsx
+	BackupKeep       int                // keep the newest backups of dst file, zero means no limit
+	BackupDays       int                // keep backups of dst file in these days, zero means no limit
+	Year             int                // year of src files without year in file or folder name, zero means by now
+	Period           string             // "2006-01", year and month of all src files, empty means by file name
+	PeriodPatterns   []*regexp.Regexp   // patterns to parse period from file name, tried in order
+	PeriodUnmatched  string             // "error" or "skip" for src file whose name matches no pattern
+	CsvEncoding      string             // encoding of csv files, "auto" means detect by content
+	CsvEncodings     map[string]string  // csv file name, encoding of this file instead of CsvEncoding
+	OrgRules         []string           // rules to choose org of uid assigned more than once, applied in order
+	OrgKolTypes      []string           // keywords of kol_type, earlier wins, "*" stands for others
+	OrgFiles         []string           // csv files, earlier wins, files not listed are after them
+	OrgByMonth       bool               // resolve org as of month of row, by rows added on or before it
+	Rollup           bool               // write rollup sheets "汇总" and "内容费用汇总"
+	Pivots           []*Pivot           // pivot tables built on dst sheets
+	Charts           []*Chart           // charts built on dst sheets
+	Theme            *Theme             // format of dst sheets
+	Schema           map[string]*Schema // task, schema
 }
 
-func InitConf() *Config {
+// OrgRuleNames are rules to choose org of uid
+var OrgRuleNames = []string{"kol_type", "date", "file"}
+
+// CsvEncodingNames are encodings of csv files supported
+var CsvEncodingNames = []string{"auto", "utf-8", "utf-16le", "utf-16be", "gbk", "gb18030"}
+
+func checkCsvEncoding(name string) error {
+	if !contains(CsvEncodingNames, name) {
+		return fmt.Errorf("csv encoding should be one of %v: %s", CsvEncodingNames, name)
+	}
+	return nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hasField check whether field is a column of any schema
+func hasField(schemas map[string]*Schema, field string) bool {
+	for _, schema := range schemas {
+		for _, column := range schema.Columns {
+			if column.Field == field {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// InitConf load config file of path, or config.ini in work dir if path is empty,
+// default config is used if config.ini not exists, but an explicit path must exist
+func InitConf(path string) (*Config, error) {
 	// setup default config
-	// about concurrency, default is enable
+	// about concurrency, default is enable and all tasks run at the same time
 	concur := true
+	workers := 0
+	readers := runtime.NumCPU()
 
-	// about task, default is all enable
-	taskMap := make(map[string]bool, 4)
+	// about task, default is all enable except mcn
+	taskMap := make(map[string]bool, 5)
 	taskMap["content"] = true
 	taskMap["campaign"] = true
 	taskMap["cps"] = true
 	taskMap["newgame"] = true
+	taskMap["mcn"] = false
+
+	// about order, default is src files by name and rows in src order
+	fileOrder := "name"
+	var rowOrder []string
+
+	// about output, default is overwrite dst file and only write xlsx
+	appendMode := false
+	odsOutput := false
+
+	// about backup, default is keep the newest 10 backups of dst file
+	backupKeep := 10
+	backupDays := 0
+
+	// about period, default is year by now and month by file name
+	year := 0
+	period := ""
+	periodPatterns := defaultPeriodPatterns()
+	periodUnmatched := "error"
+
+	// about csv, default is detect encoding of each file
+	csvEncoding := "auto"
+	csvEncodings := make(map[string]string)
+
+	// about org, default is as of month of row, then non "其他" kol_type first
+	orgRules := []string{"kol_type", "date", "file"}
+	orgKolTypes := []string{"*", "其他"}
+	var orgFiles []string
+	orgByMonth := true
+
+	// about rollup, default is write rollup sheets
+	rollup := true
+
+	// about pivot and chart, default is pivot of content and chart of monthly spend of each task
+	pivots := defaultPivots()
+	charts := defaultCharts()
+
+	// about format, default is excel table with styled and frozen header
+	theme := defaultTheme()
 
 	// about src and dst path
 	src := "src"
 	dst := "dst"
 
+	// about column mapping, parse mapping file beside config file, default if not exists
+	dir := "."
+	if path != "" {
+		dir = filepath.Dir(path)
+	}
+	schema, err := loadSchema(dir, "mapping.ini")
+	if err != nil {
+		return nil, fmt.Errorf("mapping.ini: %w", err)
+	}
+
 	// parse config file
-	viper.SetConfigName("config.ini")
 	viper.SetConfigType("toml")
-	viper.AddConfigPath(".")
+	if path != "" {
+		viper.SetConfigFile(path)
+	} else {
+		viper.SetConfigName("config.ini")
+		viper.AddConfigPath(".")
+	}
 	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok || path != "" {
+			return nil, err
+		}
 		// return default config
 		return &Config{
-			Concurrent: concur,
-			TaskMap:    taskMap,
-			SrcPath:    src,
-			DstPath:    dst,
-		}
+			Concurrent:      concur,
+			Workers:         workers,
+			Readers:         readers,
+			TaskMap:         taskMap,
+			SrcPath:         src,
+			DstPath:         dst,
+			FileOrder:       fileOrder,
+			RowOrder:        rowOrder,
+			Append:          appendMode,
+			Ods:             odsOutput,
+			BackupKeep:      backupKeep,
+			BackupDays:      backupDays,
+			Year:            year,
+			Period:          period,
+			PeriodPatterns:  periodPatterns,
+			PeriodUnmatched: periodUnmatched,
+			CsvEncoding:     csvEncoding,
+			CsvEncodings:    csvEncodings,
+			OrgRules:        orgRules,
+			OrgKolTypes:     orgKolTypes,
+			OrgFiles:        orgFiles,
+			OrgByMonth:      orgByMonth,
+			Rollup:          rollup,
+			Pivots:          pivots,
+			Charts:          charts,
+			Theme:           theme,
+			Schema:          schema,
+		}, nil
 	}
 
 	if viper.IsSet("concurrency.enable") && viper.GetInt("concurrency.enable") <= 0 {
 		concur = false
 	}
+	if viper.IsSet("concurrency.workers") && viper.GetInt("concurrency.workers") > 0 {
+		workers = viper.GetInt("concurrency.workers")
+	}
+	if viper.IsSet("concurrency.readers") && viper.GetInt("concurrency.readers") > 0 {
+		readers = viper.GetInt("concurrency.readers")
+	}
 
 	tasks := viper.GetStringMap("task")
 	for task, v := range tasks {
@@ -54,6 +205,95 @@ func InitConf() *Config {
 		}
 	}
 
+	switch order := viper.GetString("order.files"); order {
+	case "name", "month", "mtime":
+		fileOrder = order
+	case "":
+	default:
+		return nil, fmt.Errorf("order.files should be name, month or mtime: %s", order)
+	}
+	rowOrder = viper.GetStringSlice("order.rows")
+	for _, field := range rowOrder {
+		if field != "month" && !hasField(schema, field) {
+			return nil, fmt.Errorf("order.rows should be fields of mapping.ini: %s", field)
+		}
+	}
+
+	switch mode := viper.GetString("output.mode"); mode {
+	case "append":
+		appendMode = true
+	case "overwrite", "":
+	default:
+		return nil, fmt.Errorf("output.mode should be overwrite or append: %s", mode)
+	}
+	odsOutput = viper.GetBool("output.ods")
+
+	if viper.IsSet("backup.keep") && viper.GetInt("backup.keep") >= 0 {
+		backupKeep = viper.GetInt("backup.keep")
+	}
+	if viper.IsSet("backup.days") && viper.GetInt("backup.days") >= 0 {
+		backupDays = viper.GetInt("backup.days")
+	}
+
+	if viper.IsSet("period.year") && viper.GetInt("period.year") > 0 {
+		year = viper.GetInt("period.year")
+	}
+	if patterns := viper.GetStringSlice("period.patterns"); len(patterns) != 0 {
+		if periodPatterns, err = compilePeriodPatterns(patterns); err != nil {
+			return nil, err
+		}
+	}
+	switch unmatched := viper.GetString("period.unmatched"); unmatched {
+	case "error", "skip":
+		periodUnmatched = unmatched
+	case "":
+	default:
+		return nil, fmt.Errorf("period.unmatched should be error or skip: %s", unmatched)
+	}
+
+	if encoding := strings.ToLower(viper.GetString("csv.encoding")); encoding != "" {
+		if err := checkCsvEncoding(encoding); err != nil {
+			return nil, err
+		}
+		csvEncoding = encoding
+	}
+	for file, encoding := range viper.GetStringMapString("csv.files") {
+		encoding = strings.ToLower(encoding)
+		if err := checkCsvEncoding(encoding); err != nil {
+			return nil, fmt.Errorf("csv.files %s: %w", file, err)
+		}
+		csvEncodings[file] = encoding
+	}
+
+	if viper.IsSet("org.rules") {
+		orgRules = viper.GetStringSlice("org.rules")
+		for _, rule := range orgRules {
+			if !contains(OrgRuleNames, rule) {
+				return nil, fmt.Errorf("org.rules should be in %v: %s", OrgRuleNames, rule)
+			}
+		}
+	}
+	if viper.IsSet("org.kol_types") {
+		orgKolTypes = viper.GetStringSlice("org.kol_types")
+	}
+	orgFiles = viper.GetStringSlice("org.files")
+	if viper.IsSet("org.by_month") {
+		orgByMonth = viper.GetBool("org.by_month")
+	}
+
+	if viper.IsSet("rollup.enable") && viper.GetInt("rollup.enable") <= 0 {
+		rollup = false
+	}
+	if pivots, err = loadPivots(); err != nil {
+		return nil, err
+	}
+	if charts, err = loadCharts(); err != nil {
+		return nil, err
+	}
+	if theme, err = loadTheme(); err != nil {
+		return nil, err
+	}
+
 	src = viper.GetString("directory.src")
 	dst = viper.GetString("directory.dst")
 	if src == "" {
@@ -64,9 +304,32 @@ func InitConf() *Config {
 	}
 
 	return &Config{
-		Concurrent: concur,
-		TaskMap:    taskMap,
-		SrcPath:    src,
-		DstPath:    dst,
-	}
+		Concurrent:      concur,
+		Workers:         workers,
+		Readers:         readers,
+		TaskMap:         taskMap,
+		SrcPath:         src,
+		DstPath:         dst,
+		FileOrder:       fileOrder,
+		RowOrder:        rowOrder,
+		Append:          appendMode,
+		Ods:             odsOutput,
+		BackupKeep:      backupKeep,
+		BackupDays:      backupDays,
+		Year:            year,
+		Period:          period,
+		PeriodPatterns:  periodPatterns,
+		PeriodUnmatched: periodUnmatched,
+		CsvEncoding:     csvEncoding,
+		CsvEncodings:    csvEncodings,
+		OrgRules:        orgRules,
+		OrgKolTypes:     orgKolTypes,
+		OrgFiles:        orgFiles,
+		OrgByMonth:      orgByMonth,
+		Rollup:          rollup,
+		Pivots:          pivots,
+		Charts:          charts,
+		Theme:           theme,
+		Schema:          schema,
+	}, nil
 }
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// named groups of period pattern, at least one of month, range and quarter is required
+const (
+	PeriodYear    = "year"    // such as "2022" or "22"
+	PeriodMonth   = "month"   // such as "3", "03" or "三"
+	PeriodRange   = "range"   // months from first to last, such as "3-4" or "三至四"
+	PeriodQuarter = "quarter" // such as "1" or "一"
+)
+
+// default patterns of period in file name, the first matched is used
+var periodPatterns = []string{
+	// 2022年3-4月
+	`(?P<year>(?:19|20)\d{2})\s*[-_.年/]\s*(?P<range>\d{1,2}\s*[-~～至到]\s*\d{1,2})\s*月`,
+	// 2022-03, 2022年3月
+	`(?P<year>(?:19|20)\d{2})\s*[-_.年/]\s*(?P<month>\d{1,2})(?:\D|$)`,
+	// 3-4月
+	`(?P<range>\d{1,2}\s*[-~～至到]\s*\d{1,2})\s*月`,
+	// 3月
+	`(?P<month>\d{1,2})\s*月`,
+	// 三至四月
+	`(?P<range>[一二三四五六七八九十]+\s*[-~～至到]\s*[一二三四五六七八九十]+)\s*月`,
+	// 三月
+	`(?P<month>[一二三四五六七八九十]+)\s*月`,
+	// Q1
+	`[Qq](?P<quarter>[1-4])`,
+	// 第一季度
+	`第?(?P<quarter>[一二三四1-4])季度`,
+}
+
+func defaultPeriodPatterns() []*regexp.Regexp {
+	patterns, err := compilePeriodPatterns(periodPatterns)
+	if err != nil {
+		panic(err)
+	}
+	return patterns
+}
+
+func compilePeriodPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	regs := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		reg, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("period pattern %s invalid: %w", pattern, err)
+		}
+		if reg.SubexpIndex(PeriodMonth) < 0 && reg.SubexpIndex(PeriodRange) < 0 && reg.SubexpIndex(PeriodQuarter) < 0 {
+			return nil, fmt.Errorf("period pattern %s has no group named month, range or quarter", pattern)
+		}
+		regs = append(regs, reg)
+	}
+	return regs, nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompilePeriodPatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		err      string // part of error, empty if compiled
+	}{
+		{"default", periodPatterns, ""},
+		{"month", []string{`(?P<month>\d{1,2})月`}, ""},
+		{"quarter only", []string{`[Qq](?P<quarter>[1-4])`}, ""},
+		{"no month group", []string{`(?P<year>\d{4})`}, "has no group named month, range or quarter"},
+		{"unnamed group", []string{`(\d{1,2})月`}, "has no group named month, range or quarter"},
+		{"invalid", []string{`(?P<month>\d{1,2}月`}, "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regs, err := compilePeriodPatterns(tt.patterns)
+			if tt.err == "" {
+				if err != nil || len(regs) != len(tt.patterns) {
+					t.Errorf("compile = %d patterns, %v, want %d", len(regs), err, len(tt.patterns))
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// TestDefaultPeriodPatterns check groups of the first default pattern matching file name
+func TestDefaultPeriodPatterns(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups map[string]string
+	}{
+		{"2022年3-4月费用", map[string]string{PeriodYear: "2022", PeriodRange: "3-4"}},
+		{"2022年3月费用", map[string]string{PeriodYear: "2022", PeriodMonth: "3"}},
+		{"费用2022-03", map[string]string{PeriodYear: "2022", PeriodMonth: "03"}},
+		{"2022.3.15导出", map[string]string{PeriodYear: "2022", PeriodMonth: "3"}},
+		{"11至12月费用", map[string]string{PeriodRange: "11至12"}},
+		{"3月费用", map[string]string{PeriodMonth: "3"}},
+		{"三至四月费用", map[string]string{PeriodRange: "三至四"}},
+		{"十二月费用", map[string]string{PeriodMonth: "十二"}},
+		{"费用Q2", map[string]string{PeriodQuarter: "2"}},
+		{"第一季度费用", map[string]string{PeriodQuarter: "一"}},
+		{"费用", nil},
+	}
+	patterns := defaultPeriodPatterns()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var groups map[string]string
+			for _, pattern := range patterns {
+				found := pattern.FindStringSubmatch(tt.name)
+				if found == nil {
+					continue
+				}
+				groups = make(map[string]string)
+				for i, name := range pattern.SubexpNames() {
+					if name != "" && found[i] != "" {
+						groups[name] = found[i]
+					}
+				}
+				break
+			}
+			if len(groups) != len(tt.groups) {
+				t.Fatalf("groups = %q, want %q", groups, tt.groups)
+			}
+			for name, value := range tt.groups {
+				if groups[name] != value {
+					t.Errorf("groups = %q, want %q", groups, tt.groups)
+				}
+			}
+		})
+	}
+}
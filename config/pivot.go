@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+)
+
+// Pivot describe a pivot table built on a dst sheet, names are header names in the first row of source
+type Pivot struct {
+	Sheet    string   `mapstructure:"sheet"`    // dst sheet of pivot table, rebuilt in every run
+	Source   string   `mapstructure:"source"`   // sheet with header in the first row
+	Rows     []string `mapstructure:"rows"`     // row fields
+	Columns  []string `mapstructure:"columns"`  // column fields
+	Data     []string `mapstructure:"data"`     // value fields
+	Filter   []string `mapstructure:"filter"`   // filter fields
+	Subtotal string   `mapstructure:"subtotal"` // how values are summarized, SubtotalNames
+}
+
+// Chart describe a chart of sums of source columns grouped by category column
+type Chart struct {
+	Sheet    string   `mapstructure:"sheet"`    // dst sheet of chart and table of sums, rebuilt in every run
+	Type     string   `mapstructure:"type"`     // ChartTypeNames
+	Title    string   `mapstructure:"title"`    // title of chart, sheet name if empty
+	Source   string   `mapstructure:"source"`   // sheet with header in the first row
+	Category string   `mapstructure:"category"` // header of column to group by, x axis of chart
+	Series   []string `mapstructure:"series"`   // headers of columns to sum, one series each
+}
+
+// SubtotalNames are ways to summarize values of pivot table
+var SubtotalNames = []string{"Sum", "Count", "Average", "Max", "Min"}
+
+// ChartTypeNames are types of chart supported
+var ChartTypeNames = []string{"line", "col", "colStacked", "bar", "barStacked", "area", "areaStacked", "pie"}
+
+func defaultPivots() []*Pivot {
+	return []*Pivot{
+		{
+			Sheet:    "内容费用透视",
+			Source:   "大神内域作者费用明细",
+			Rows:     []string{"机构", "部门"},
+			Columns:  []string{"月份"},
+			Data:     []string{"视频费用", "图文费用", "不能区分"},
+			Subtotal: "Sum",
+		},
+	}
+}
+
+func defaultCharts() []*Chart {
+	return []*Chart{
+		{
+			Sheet:    "月度费用趋势",
+			Type:     "line",
+			Title:    "各任务月度费用",
+			Source:   "汇总",
+			Category: "月份",
+			Series:   []string{"大神内域作者费用明细", "活动", "CPS分发", "新游预约", "MCN机构费用明细"},
+		},
+	}
+}
+
+// loadPivots read pivot tables of config, default ones if not set, an empty list means none
+func loadPivots() ([]*Pivot, error) {
+	if !viper.IsSet("pivot") {
+		return defaultPivots(), nil
+	}
+	pivots := make([]*Pivot, 0)
+	if err := viper.UnmarshalKey("pivot", &pivots); err != nil {
+		return nil, err
+	}
+	for _, pivot := range pivots {
+		if pivot.Sheet == "" || pivot.Source == "" || len(pivot.Data) == 0 {
+			return nil, fmt.Errorf("pivot %s: sheet, source and data are required", pivot.Sheet)
+		}
+		if pivot.Subtotal == "" {
+			pivot.Subtotal = "Sum"
+		} else if !contains(SubtotalNames, pivot.Subtotal) {
+			return nil, fmt.Errorf("pivot %s: subtotal should be one of %v: %s", pivot.Sheet, SubtotalNames, pivot.Subtotal)
+		}
+	}
+	return pivots, nil
+}
+
+// loadCharts read charts of config, default ones if not set, an empty list means none
+func loadCharts() ([]*Chart, error) {
+	if !viper.IsSet("chart") {
+		return defaultCharts(), nil
+	}
+	charts := make([]*Chart, 0)
+	if err := viper.UnmarshalKey("chart", &charts); err != nil {
+		return nil, err
+	}
+	for _, chart := range charts {
+		if chart.Sheet == "" || chart.Source == "" || chart.Category == "" || len(chart.Series) == 0 {
+			return nil, fmt.Errorf("chart %s: sheet, source, category and series are required", chart.Sheet)
+		}
+		if chart.Type == "" {
+			chart.Type = "line"
+		} else if !contains(ChartTypeNames, chart.Type) {
+			return nil, fmt.Errorf("chart %s: type should be one of %v: %s", chart.Sheet, ChartTypeNames, chart.Type)
+		}
+	}
+	return charts, nil
+}
@@ -0,0 +1,196 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+)
+
+// Schema describe how src sheets of one task map into dst sheet
+type Schema struct {
+	Sheets  []string `mapstructure:"sheets"`  // keyword of src sheet name
+	Exclude []string `mapstructure:"exclude"` // skip src sheet whose name contains any of them
+	Anchor  string   `mapstructure:"anchor"`  // header value to search, its row is header row
+	Skip    string   `mapstructure:"skip"`    // skip src row whose key col contains it
+	Target  string   `mapstructure:"target"`  // dst sheet name
+	Columns []Column `mapstructure:"columns"`
+}
+
+// Column map one field between src and dst
+// header is empty for field computed by collector, such as month and org
+// title is header of dst col, written into the first row of dst sheet
+// dst is empty for field only used by collector, such as dynamic type
+type Column struct {
+	Field    string   `mapstructure:"field"`
+	Header   string   `mapstructure:"header"`   // src header name
+	Aliases  []string `mapstructure:"aliases"`  // other src header names of the same field
+	Exclude  []string `mapstructure:"exclude"`  // src header must not contain any of them
+	Optional bool     `mapstructure:"optional"` // src header can be absent
+	Required bool     `mapstructure:"required"` // src value can not be empty, see Schema.Required
+	Dst      string   `mapstructure:"dst"`      // dst col letter
+	Title    string   `mapstructure:"title"`    // dst header name, header or field if empty
+	Type     string   `mapstructure:"type"`     // value type, TypeText if empty
+}
+
+// value types of column, values of amount and count are written as numbers
+const (
+	TypeText   = "text"
+	TypeAmount = "amount" // such as "1,234.50", "¥800", "1.2万" or "12,000元"
+	TypeCount  = "count"  // such as "12,000" or "3.4w"
+)
+
+// Headers return header name and all aliases
+func (c *Column) Headers() []string {
+	if c.Header == "" {
+		return nil
+	}
+	return append([]string{c.Header}, c.Aliases...)
+}
+
+// Required return fields whose src value can not be empty, a row with all of them empty is the end of data,
+// and a row with some of them empty is skipped
+func (s *Schema) Required() []string {
+	fields := make([]string, 0)
+	for _, column := range s.Columns {
+		if column.Required && column.Header != "" {
+			fields = append(fields, column.Field)
+		}
+	}
+	return fields
+}
+
+// Column return the column of field, nil if not found
+func (s *Schema) Column(field string) *Column {
+	for i := range s.Columns {
+		if s.Columns[i].Field == field {
+			return &s.Columns[i]
+		}
+	}
+	return nil
+}
+
+func defaultSchema() map[string]*Schema {
+	sum := []string{"求和"}
+	uidAliases := []string{"uid", "用户ID"}
+	moneyAliases := []string{"税前金额（自动计算）", "税前金额(自动计算)"}
+	common := func(target, key string) *Schema {
+		return &Schema{
+			Sheets: []string{target},
+			Anchor: "运营部门",
+			Skip:   "辅助",
+			Target: target,
+			Columns: []Column{
+				{Field: "key", Header: key, Required: true},
+				{Field: "department", Header: "运营部门", Optional: true, Required: true},
+				{Field: "game", Header: "游戏产品", Optional: true, Required: true},
+				{Field: "startDate", Header: "开始日期", Optional: true, Required: true},
+				{Field: "endDate", Header: "结束日期", Optional: true, Required: true},
+				{Field: "money", Header: "金额", Optional: true, Type: TypeAmount},
+			},
+		}
+	}
+
+	return map[string]*Schema{
+		"content": {
+			Sheets:  []string{"内容创作者", "内容采购"},
+			Exclude: []string{"论坛"},
+			Anchor:  "运营部门",
+			Target:  "大神内域作者费用明细",
+			Columns: []Column{
+				{Field: "month", Dst: "A", Title: "月份"},
+				{Field: "org", Dst: "B", Title: "机构"},
+				{Field: "department", Header: "运营部门", Dst: "C", Title: "部门"},
+				{Field: "game", Header: "游戏产品", Dst: "D", Title: "游戏"},
+				{Field: "uid", Header: "UID", Aliases: uidAliases, Required: true, Dst: "E", Title: "UID"},
+				{Field: "nickName", Header: "昵称", Required: true, Dst: "F", Title: "昵称"},
+				{Field: "videoMoney", Dst: "G", Title: "视频费用"},
+				{Field: "textMoney", Dst: "H", Title: "图文费用"},
+				{Field: "unclsMoney", Dst: "I", Title: "不能区分"},
+				{Field: "type", Dst: "J", Title: "类别"},
+				{Field: "sponsor", Header: "出资方", Dst: "K", Title: "出资方"},
+				{Field: "readCnt", Header: "阅读量", Aliases: []string{"阅读数"}, Exclude: sum, Dst: "L", Type: TypeCount, Title: "阅读量"},
+				{Field: "dynType", Header: "动态类型", Aliases: []string{"内容类型"}},
+				{Field: "money", Header: "税前金额（自动计算)", Aliases: moneyAliases, Exclude: sum, Required: true, Type: TypeAmount},
+			},
+		},
+		"campaign": common("活动", "入库活动名"),
+		"cps":      common("CPS分发", "项目名称"),
+		"newgame":  common("新游预约", "项目名称"),
+		"mcn": {
+			Sheets: []string{"MCN"},
+			Anchor: "运营部门",
+			Target: "MCN机构费用明细",
+			Columns: []Column{
+				{Field: "month", Dst: "A", Title: "月份"},
+				{Field: "org", Dst: "B", Title: "机构"},
+				{Field: "agency", Header: "MCN机构", Required: true, Dst: "C", Title: "MCN机构"},
+				{Field: "department", Header: "运营部门", Dst: "D", Title: "部门"},
+				{Field: "game", Header: "游戏产品", Dst: "E", Title: "游戏"},
+				{Field: "uid", Header: "UID", Aliases: uidAliases, Required: true, Dst: "F", Title: "UID"},
+				{Field: "nickName", Header: "昵称", Dst: "G", Title: "昵称"},
+				{Field: "money", Header: "税前金额（自动计算)", Aliases: moneyAliases, Exclude: sum, Required: true, Dst: "H", Type: TypeAmount, Title: "金额"},
+				{Field: "sponsor", Header: "出资方", Dst: "I", Title: "出资方"},
+			},
+		},
+	}
+}
+
+// loadSchema read mapping file, task found in it replace the default one
+func loadSchema(path, name string) (map[string]*Schema, error) {
+	schemas := defaultSchema()
+
+	v := viper.New()
+	v.SetConfigName(name)
+	v.SetConfigType("toml")
+	v.AddConfigPath(path)
+	if err := v.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+			return schemas, nil
+		}
+		return nil, err
+	}
+
+	for task := range v.AllSettings() {
+		schema := &Schema{}
+		if err := v.UnmarshalKey(task, schema); err != nil {
+			return nil, err
+		}
+		for _, column := range schema.Columns {
+			switch column.Type {
+			case "", TypeText, TypeAmount, TypeCount:
+			default:
+				return nil, fmt.Errorf("%s.%s: unknown type %s", task, column.Field, column.Type)
+			}
+		}
+		if def, ok := schemas[task]; ok {
+			// fill the missing part from default
+			if len(schema.Sheets) == 0 {
+				schema.Sheets = def.Sheets
+			}
+			if schema.Exclude == nil {
+				schema.Exclude = def.Exclude
+			}
+			if schema.Anchor == "" {
+				schema.Anchor = def.Anchor
+			}
+			if schema.Skip == "" {
+				schema.Skip = def.Skip
+			}
+			if schema.Target == "" {
+				schema.Target = def.Target
+			}
+			if len(schema.Columns) == 0 {
+				schema.Columns = def.Columns
+			} else if len(schema.Required()) == 0 {
+				// mapping file of older versions has no required fields
+				for i := range schema.Columns {
+					if column := def.Column(schema.Columns[i].Field); column != nil {
+						schema.Columns[i].Required = column.Required
+					}
+				}
+			}
+		}
+		schemas[task] = schema
+	}
+
+	return schemas, nil
+}
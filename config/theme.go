@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"regexp"
+)
+
+// Theme describe how dst sheets are formatted, only sheets created in this run are formatted
+type Theme struct {
+	Enable      bool    `mapstructure:"enable"`
+	TableStyle  string  `mapstructure:"table_style"`  // style of excel table, such as "TableStyleMedium2", empty means autofilter only
+	HeaderFill  string  `mapstructure:"header_fill"`  // background color of header row, such as "#4472C4", empty means none
+	HeaderColor string  `mapstructure:"header_color"` // font color of header row, empty means default
+	HeaderBold  bool    `mapstructure:"header_bold"`  // bold font of header row
+	Freeze      bool    `mapstructure:"freeze"`       // freeze header row when scrolling
+	MinWidth    float64 `mapstructure:"min_width"`    // column width is measured from content, CJK chars count twice
+	MaxWidth    float64 `mapstructure:"max_width"`
+}
+
+var (
+	colorReg      = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+	tableStyleReg = regexp.MustCompile(`^TableStyle(Light|Medium|Dark)\d{1,2}$`)
+)
+
+func defaultTheme() *Theme {
+	return &Theme{
+		Enable:      true,
+		TableStyle:  "TableStyleMedium2",
+		HeaderFill:  "#4472C4",
+		HeaderColor: "#FFFFFF",
+		HeaderBold:  true,
+		Freeze:      true,
+		MinWidth:    8,
+		MaxWidth:    50,
+	}
+}
+
+// loadTheme read theme of config, keys not set are default
+func loadTheme() (*Theme, error) {
+	theme := defaultTheme()
+	if !viper.IsSet("theme") {
+		return theme, nil
+	}
+	if err := viper.UnmarshalKey("theme", theme); err != nil {
+		return nil, err
+	}
+	if theme.TableStyle != "" && !tableStyleReg.MatchString(theme.TableStyle) {
+		return nil, fmt.Errorf("theme.table_style should be like TableStyleMedium2: %s", theme.TableStyle)
+	}
+	for _, color := range []string{theme.HeaderFill, theme.HeaderColor} {
+		if color != "" && !colorReg.MatchString(color) {
+			return nil, fmt.Errorf("theme color should be like #4472C4: %s", color)
+		}
+	}
+	if theme.MinWidth <= 0 || theme.MaxWidth < theme.MinWidth {
+		return nil, fmt.Errorf("theme width should be 0 < min_width <= max_width: %v, %v", theme.MinWidth, theme.MaxWidth)
+	}
+	return theme, nil
+}
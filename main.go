@@ -1,25 +1,107 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"excel/collect"
 	"excel/config"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"text/tabwriter"
 )
 
 func main() {
-	conf := config.InitConf()
+	opts, err := parseArgs(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		fmt.Println(err)
+		os.Exit(exitConfig)
+	}
+
+	conf, err := config.InitConf(opts.configPath)
+	if err == nil {
+		err = opts.apply(conf)
+	}
+	if err != nil {
+		fmt.Println("配置错误：", err)
+		opts.exit(exitConfig, err, nil)
+	}
 	collectInstance := collect.NewCollect(conf)
-	err := collectInstance.Run()
+
+	switch opts.command {
+	case cmdBackups:
+		printBackups(collectInstance)
+		return
+	case cmdRestore:
+		timestamp := opts.args[0]
+		if err := collectInstance.Restore(timestamp); err != nil {
+			fmt.Println("恢复失败：", err)
+			printBackups(collectInstance)
+			opts.exit(exitCode(err, nil), err, nil)
+		}
+		fmt.Println("已恢复备份", timestamp)
+		opts.exit(exitOK, nil, nil)
+	}
+
+	// ctrl-c stops the tasks not finished
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err = collectInstance.RunContext(ctx)
+	stop()
 
 	if err != nil {
-		fmt.Println(err)
+		printErrors(err)
 		fmt.Println("出现问题！！！请到下面链接反馈问题")
 		fmt.Println("https://docs.google.com/spreadsheets/d/1GkcPa0WjVt2UBVnRNQ-1SO49vYsR0CQgk3qtA-VxE-Y/edit#gid=0")
 	} else {
 		fmt.Println("没有问题")
 	}
-	fmt.Println("请按回车退出")
-	b := make([]byte, 1)
-	os.Stdin.Read(b)
+	results := collectInstance.Results()
+	opts.exit(exitCode(err, results), err, results)
+}
+
+// exit write summary if required, and wait for enter before exit with code,
+// so the window opened by double click not closes at once
+func (opts *options) exit(code int, err error, results []collect.TaskResult) {
+	if opts.summary != "" {
+		if err := newSummary(code, err, results).write(opts.summary); err != nil {
+			fmt.Println("写入运行摘要失败：", err)
+		}
+	}
+	if !opts.noPause {
+		fmt.Println("请按回车退出")
+		b := make([]byte, 1)
+		os.Stdin.Read(b)
+	}
+	os.Exit(code)
+}
+
+// printErrors print a table of all task errors, one row for each error
+func printErrors(err error) {
+	var runErr collect.RunError
+	if !errors.As(err, &runErr) {
+		fmt.Println(err)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "任务\t文件\t工作表\t原因")
+	for _, taskErr := range runErr {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", taskErr.Task, taskErr.File, taskErr.Sheet, taskErr.Err)
+	}
+	w.Flush()
+}
+
+// printBackups print timestamps of all backups which can be restored
+func printBackups(c *collect.Collect) {
+	backups, err := c.Backups("项目立项及实际费用明细.xlsx")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("可恢复的备份：")
+	for _, backup := range backups {
+		fmt.Println(backup.Timestamp)
+	}
 }
@@ -0,0 +1,111 @@
+// code for writing excel formulas as OpenFormula of ods, references are put in brackets,
+// such as SUM(D2:F2) to SUM([.D2:.F2]) and 'a b'!$D:$D to [$'a b'.$D:.$D], and arguments are split by ";"
+
+package ods
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	cellRefReg = regexp.MustCompile(`^\$?[A-Za-z]{1,3}\$?[0-9]+$`)
+	colRefReg  = regexp.MustCompile(`^\$?[A-Za-z]{1,3}$`)
+)
+
+// nameChar check whether c can be in function name, sheet name not quoted or reference
+func nameChar(c byte) bool {
+	return c == '_' || c == '$' || c == '.' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= 0x80
+}
+
+// quoted get end of text quoted by q from start, a doubled quote is the quote itself
+func quoted(formula string, start int, q byte) int {
+	for i := start + 1; i < len(formula); i++ {
+		if formula[i] != q {
+			continue
+		}
+		if i+1 < len(formula) && formula[i+1] == q {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(formula)
+}
+
+// name get end of name from start
+func name(formula string, start int) int {
+	i := start
+	for i < len(formula) && nameChar(formula[i]) {
+		i++
+	}
+	return i
+}
+
+// odfFormula convert excel formula without "=" to OpenFormula
+func odfFormula(formula string) string {
+	var b strings.Builder
+	for i := 0; i < len(formula); {
+		c := formula[i]
+		switch {
+		case c == '"':
+			end := quoted(formula, i, '"')
+			b.WriteString(formula[i:end])
+			i = end
+		case c == ',':
+			b.WriteByte(';')
+			i++
+		case c == '\'' || nameChar(c):
+			end := i
+			if c == '\'' {
+				end = quoted(formula, i, '\'')
+			} else {
+				end = name(formula, i)
+			}
+			sheet, token := "", formula[i:end]
+			if end < len(formula) && formula[end] == '!' {
+				sheet = token
+				if c != '\'' {
+					sheet = "'" + sheet + "'"
+				}
+				token, end = formula[end+1:name(formula, end+1)], name(formula, end+1)
+			}
+			ref, refEnd := reference(formula, token, end)
+			if ref == "" {
+				if sheet != "" {
+					b.WriteString(formula[i:end]) // not known, left as it is
+				} else {
+					b.WriteString(token)
+				}
+				i = end
+				continue
+			}
+			b.WriteString("[")
+			if sheet != "" {
+				b.WriteString("$" + sheet)
+			}
+			b.WriteString("." + strings.Replace(ref, ":", ":.", 1) + "]")
+			i = refEnd
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// reference get cell or range reference of token ending at end, and end of it, a range of cols has both ends,
+// empty if token is not a reference, such as name of function
+func reference(formula, token string, end int) (string, int) {
+	if end < len(formula) && formula[end] == ':' {
+		to := name(formula, end+1)
+		last := formula[end+1 : to]
+		if cellRefReg.MatchString(token) && cellRefReg.MatchString(last) || colRefReg.MatchString(token) && colRefReg.MatchString(last) {
+			return token + ":" + last, to
+		}
+	}
+	if cellRefReg.MatchString(token) && (end == len(formula) || formula[end] != '(') {
+		return token, end
+	}
+	return "", end
+}
@@ -0,0 +1,290 @@
+// Package ods read and write OpenDocument spreadsheets (.ods) of LibreOffice and WPS,
+// cell values are read as text, numbers in plain decimal and dates in "2006/1/2"
+
+package ods
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const mimeType = "application/vnd.oasis.opendocument.spreadsheet"
+
+// namespaces of elements and attributes used
+const (
+	nsOffice = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	nsStyle  = "urn:oasis:names:tc:opendocument:xmlns:style:1.0"
+	nsTable  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	nsText   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+)
+
+var ErrNoContent = errors.New("ods: no content.xml in file")
+
+// Workbook is a parsed ods file
+type Workbook struct {
+	sheets []*Sheet
+}
+
+// Sheet is a table of workbook
+type Sheet struct {
+	Name    string
+	Visible bool
+	rows    [][]string // values by row and col, blank row is nil
+}
+
+// Is check whether content is an ods file by its mimetype entry
+func Is(content []byte) bool {
+	r, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return false
+	}
+	for _, file := range r.File {
+		if file.Name != "mimetype" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return false
+		}
+		defer rc.Close()
+		mime, err := io.ReadAll(rc)
+		return err == nil && strings.TrimSpace(string(mime)) == mimeType
+	}
+	return false
+}
+
+// OpenBytes read ods file from content
+func OpenBytes(content []byte) (*Workbook, error) {
+	r, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("ods: %w", err)
+	}
+	for _, file := range r.File {
+		if file.Name != "content.xml" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("ods: %w", err)
+		}
+		defer rc.Close()
+		wb, err := parseContent(rc)
+		if err != nil {
+			return nil, fmt.Errorf("ods: %w", err)
+		}
+		return wb, nil
+	}
+	return nil, ErrNoContent
+}
+
+// Sheets return tables in order of workbook
+func (wb *Workbook) Sheets() []*Sheet {
+	return wb.sheets
+}
+
+// Sheet return table of name, nil if not found
+func (wb *Workbook) Sheet(name string) *Sheet {
+	for _, sheet := range wb.sheets {
+		if sheet.Name == name {
+			return sheet
+		}
+	}
+	return nil
+}
+
+// Rows return values of each row, trailing blank cells are trimmed and blank row is nil
+func (s *Sheet) Rows() [][]string {
+	rows := make([][]string, len(s.rows))
+	for i, values := range s.rows {
+		if values != nil {
+			rows[i] = append([]string(nil), values...)
+		}
+	}
+	return rows
+}
+
+func attr(e xml.StartElement, space, local string) string {
+	for _, a := range e.Attr {
+		if a.Name.Space == space && a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// repeated get count of repeated rows or cells, at least one
+func repeated(e xml.StartElement, local string) int {
+	n, err := strconv.Atoi(attr(e, nsTable, local))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// parseContent parse tables in content.xml, blank rows and cells repeated at the end are not kept,
+// so the whole sheet filled by one style does not matter
+func parseContent(r io.Reader) (*Workbook, error) {
+	wb := &Workbook{}
+	hidden := make(map[string]bool) // table style name, not displayed
+	var (
+		styleName string
+		sheet     *Sheet
+		row       []string
+		rowRepeat int
+		blankRows int // blank rows not appended yet
+		blankCols int // blank cells not appended yet
+	)
+	d := xml.NewDecoder(r)
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch e := token.(type) {
+		case xml.StartElement:
+			switch {
+			case e.Name.Space == nsStyle && e.Name.Local == "style":
+				styleName = attr(e, nsStyle, "name")
+			case e.Name.Space == nsStyle && e.Name.Local == "table-properties":
+				if attr(e, nsTable, "display") == "false" {
+					hidden[styleName] = true
+				}
+			case e.Name.Space == nsTable && e.Name.Local == "table":
+				sheet = &Sheet{Name: attr(e, nsTable, "name"), Visible: !hidden[attr(e, nsTable, "style-name")]}
+				wb.sheets = append(wb.sheets, sheet)
+				blankRows = 0
+			case sheet == nil:
+			case e.Name.Space == nsTable && e.Name.Local == "table-row":
+				row, rowRepeat, blankCols = nil, repeated(e, "number-rows-repeated"), 0
+			case e.Name.Space == nsTable && (e.Name.Local == "table-cell" || e.Name.Local == "covered-table-cell"):
+				value, err := cellValue(d, e)
+				if err != nil {
+					return nil, err
+				}
+				n := repeated(e, "number-columns-repeated")
+				if value == "" {
+					blankCols += n
+					continue
+				}
+				for ; blankCols > 0; blankCols-- {
+					row = append(row, "")
+				}
+				for i := 0; i < n; i++ {
+					row = append(row, value)
+				}
+			}
+		case xml.EndElement:
+			switch {
+			case sheet == nil:
+			case e.Name.Space == nsTable && e.Name.Local == "table-row":
+				if row == nil {
+					blankRows += rowRepeat
+					continue
+				}
+				for ; blankRows > 0; blankRows-- {
+					sheet.rows = append(sheet.rows, nil)
+				}
+				for i := 0; i < rowRepeat; i++ {
+					sheet.rows = append(sheet.rows, row) // copied when read by Rows
+				}
+			case e.Name.Space == nsTable && e.Name.Local == "table":
+				sheet = nil
+			}
+		}
+	}
+	return wb, nil
+}
+
+// cellValue read value of cell element, the element is consumed
+func cellValue(d *xml.Decoder, e xml.StartElement) (string, error) {
+	text, err := cellText(d)
+	if err != nil {
+		return "", err
+	}
+	switch attr(e, nsOffice, "value-type") {
+	case "float", "percentage", "currency":
+		if v, err := strconv.ParseFloat(attr(e, nsOffice, "value"), 64); err == nil {
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+	case "date":
+		if v := formatDate(attr(e, nsOffice, "date-value")); v != "" {
+			return v, nil
+		}
+	case "boolean":
+		return strings.ToUpper(attr(e, nsOffice, "boolean-value")), nil
+	}
+	return text, nil
+}
+
+// formatDate render date value such as "2021-09-12" or "2021-09-12T13:13:00"
+func formatDate(v string) string {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t.Format("2006/1/2")
+	}
+	if t, err := time.Parse("2006-01-02T15:04:05", strings.SplitN(v, ".", 2)[0]); err == nil {
+		return t.Format("2006/1/2 15:04:05")
+	}
+	return ""
+}
+
+// cellText read paragraphs of cell until the end of cell, joined by line break
+func cellText(d *xml.Decoder) (string, error) {
+	var (
+		b          strings.Builder
+		paragraphs int
+		depth      = 1
+	)
+	for depth > 0 {
+		token, err := d.Token()
+		if err != nil {
+			return "", err
+		}
+		switch e := token.(type) {
+		case xml.StartElement:
+			depth++
+			if e.Name.Space != nsText {
+				if err := d.Skip(); err != nil { // annotation and so on
+					return "", err
+				}
+				depth--
+				continue
+			}
+			switch e.Name.Local {
+			case "p":
+				if paragraphs++; paragraphs > 1 {
+					b.WriteString("\n")
+				}
+			case "s":
+				b.WriteString(strings.Repeat(" ", repeatedSpaces(e)))
+			case "tab":
+				b.WriteString("\t")
+			case "line-break":
+				b.WriteString("\n")
+			}
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if paragraphs > 0 {
+				b.Write(e)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+func repeatedSpaces(e xml.StartElement) int {
+	n, err := strconv.Atoi(attr(e, nsText, "c"))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
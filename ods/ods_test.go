@@ -0,0 +1,179 @@
+package ods
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteRead(t *testing.T) {
+	tables := []Table{
+		{
+			Name: "明细",
+			Rows: [][]Cell{
+				{{Value: "日期"}, {Value: "游戏"}, {Value: "费用"}, {Value: "数量"}, {Value: "比例"}, {Value: "备注"}},
+				{{Value: "2021-09-12", Type: Date}, {Value: "游戏A"}, {Value: "1234.5", Type: Decimal}, {Value: "3", Type: Integer}, {Value: "0.25", Type: Float}, {Value: "第一行\n第二行"}},
+				{{Value: "2021-09-12T08:30:00", Type: Date}, {Value: "游戏A"}, {Value: "1234.5", Type: Decimal}, {Value: "3", Type: Integer}, {Value: "0.25", Type: Float}, {Value: "第一行\n第二行"}},
+				{{Value: "2021-09-12T08:30:00", Type: Date}, {Value: "游戏A"}, {Value: "1234.5", Type: Decimal}, {Value: "3", Type: Integer}, {Value: "0.25", Type: Float}, {Value: "第一行\n第二行"}},
+				{},
+				{},
+				{{}, {}, {}, {Value: "x"}, {Value: "x"}, {Value: "x"}},
+				{{Value: "2021-10-01", Type: Month}, {Value: "不是数字", Type: Decimal}, {Value: "不是日期", Type: Date}, {}, {}},
+				{},
+			},
+		},
+		{
+			Name:   "汇总",
+			Hidden: true,
+			Rows: [][]Cell{
+				{{Value: "游戏"}, {Value: "合计"}},
+				{{Value: "游戏A"}, {Value: "2469", Type: Decimal, Formula: "SUM(明细!C2:C3)"}},
+				{{Value: "a<b&c"}, {Value: "2469", Type: Decimal, Formula: "SUM(B2:B2)"}},
+			},
+		},
+	}
+	var b bytes.Buffer
+	if err := Write(&b, tables); err != nil {
+		t.Fatal(err)
+	}
+	content := b.Bytes()
+	if !Is(content) {
+		t.Fatal("written file is not ods")
+	}
+	wb, err := OpenBytes(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(wb.Sheets()) != 2 {
+		t.Fatalf("sheets = %d, want 2", len(wb.Sheets()))
+	}
+	detail := wb.Sheet("明细")
+	if detail == nil || !detail.Visible {
+		t.Fatalf("sheet 明细 = %+v, want visible", detail)
+	}
+	same := []string{"2021/9/12 08:30:00", "游戏A", "1234.5", "3", "0.25", "第一行\n第二行"}
+	want := [][]string{
+		{"日期", "游戏", "费用", "数量", "比例", "备注"},
+		{"2021/9/12", "游戏A", "1234.5", "3", "0.25", "第一行\n第二行"},
+		same,
+		same,
+		nil,
+		nil,
+		{"", "", "", "x", "x", "x"},
+		{"2021/10/1", "不是数字", "不是日期"},
+	}
+	if got := detail.Rows(); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows of 明细 = %q, want %q", got, want)
+	}
+
+	rollup := wb.Sheet("汇总")
+	if rollup == nil || rollup.Visible {
+		t.Fatalf("sheet 汇总 = %+v, want hidden", rollup)
+	}
+	want = [][]string{{"游戏", "合计"}, {"游戏A", "2469"}, {"a<b&c", "2469"}}
+	if got := rollup.Rows(); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows of 汇总 = %q, want %q", got, want)
+	}
+}
+
+// TestWriteRepeated check the same cells and rows are written once with repeated count
+func TestWriteRepeated(t *testing.T) {
+	row := []Cell{{Value: "1", Type: Integer}, {Value: "1", Type: Integer}, {Value: "1", Type: Integer}, {Value: "a"}}
+	formulas := []Cell{{Value: "1", Formula: "A1"}, {Value: "1", Formula: "A1"}}
+	var b bytes.Buffer
+	if err := Write(&b, []Table{{Name: "Sheet1", Rows: [][]Cell{row, row, formulas, {}, {}}}}); err != nil {
+		t.Fatal(err)
+	}
+	wb, err := OpenBytes(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"1", "1", "1", "a"}, {"1", "1", "1", "a"}, {"1", "1"}}
+	if got := wb.Sheets()[0].Rows(); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+
+	content := contentXML(t, b.Bytes())
+	for _, s := range []string{`table:number-rows-repeated="2"`, `table:number-columns-repeated="3"`} {
+		if !strings.Contains(content, s) {
+			t.Errorf("content.xml has no %s", s)
+		}
+	}
+	if strings.Count(content, `table:formula=`) != 2 {
+		t.Errorf("formula cells are repeated: %s", content)
+	}
+}
+
+func TestWriteTypes(t *testing.T) {
+	tests := []struct {
+		cell Cell
+		want string
+	}{
+		{Cell{Value: "1234.5", Type: Decimal}, `<table:table-cell table:style-name="ce2" office:value-type="float" office:value="1234.5"><text:p>1234.5</text:p></table:table-cell>`},
+		{Cell{Value: "12", Type: Integer}, `<table:table-cell table:style-name="ce1" office:value-type="float" office:value="12"><text:p>12</text:p></table:table-cell>`},
+		{Cell{Value: "0.5", Type: Float}, `<table:table-cell office:value-type="float" office:value="0.5"><text:p>0.5</text:p></table:table-cell>`},
+		{Cell{Value: "2021-09-12", Type: Date}, `<table:table-cell table:style-name="ce3" office:value-type="date" office:date-value="2021-09-12"><text:p>2021/9/12</text:p></table:table-cell>`},
+		{Cell{Value: "2021-09-01", Type: Month}, `<table:table-cell table:style-name="ce4" office:value-type="date" office:date-value="2021-09-01"><text:p>2021年9月</text:p></table:table-cell>`},
+		{Cell{Value: "12", Type: Decimal, Formula: "SUM(A1:B1)"}, `<table:table-cell table:formula="of:=SUM([.A1:.B1])" table:style-name="ce2" office:value-type="float" office:value="12"><text:p>12</text:p></table:table-cell>`},
+		{Cell{Value: "9月", Type: Date}, `<table:table-cell office:value-type="string"><text:p>9月</text:p></table:table-cell>`},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := Write(&b, []Table{{Name: "Sheet1", Rows: [][]Cell{{tt.cell}}}}); err != nil {
+			t.Fatal(err)
+		}
+		if content := contentXML(t, b.Bytes()); !strings.Contains(content, tt.want) {
+			t.Errorf("cell %+v is not written as %s", tt.cell, tt.want)
+		}
+	}
+}
+
+func TestOdfFormula(t *testing.T) {
+	tests := []struct {
+		formula, want string
+	}{
+		{"SUM(D2:F2)", "SUM([.D2:.F2])"},
+		{"$A$1+B2*2", "[.$A$1]+[.B2]*2"},
+		{"SUM('CPS分发'!$G:$G)", "SUM([$'CPS分发'.$G:.$G])"},
+		{"SUM(Sheet1!A1:B2,'a''b'!C3)", "SUM([$'Sheet1'.A1:.B2];[$'a''b'.C3])"},
+		{`SUMIFS(C:C,A:A,">="&$A2,B:B,"a,b"&"")`, `SUMIFS([.C:.C];[.A:.A];">="&[.$A2];[.B:.B];"a,b"&"")`},
+		{"DATE(YEAR($A2),MONTH($A2)+1,1)", "DATE(YEAR([.$A2]);MONTH([.$A2])+1;1)"},
+		{"LOG10(A1)", "LOG10([.A1])"},
+		{`IF(A1="B2",1.5,TRUE)`, `IF([.A1]="B2";1.5;TRUE)`},
+	}
+	for _, tt := range tests {
+		if got := odfFormula(tt.formula); got != tt.want {
+			t.Errorf("odfFormula(%q) = %q, want %q", tt.formula, got, tt.want)
+		}
+	}
+}
+
+// contentXML get content.xml of ods file
+func contentXML(t *testing.T, file []byte) string {
+	t.Helper()
+	r, err := zip.NewReader(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range r.File {
+		if f.Name != "content.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		content, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+	t.Fatal("no content.xml")
+	return ""
+}
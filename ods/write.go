@@ -0,0 +1,227 @@
+package ods
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CellType decide how cell is written
+type CellType int
+
+const (
+	Text    CellType = iota // string as it is
+	Integer                 // number shown as #,##0
+	Decimal                 // number shown as #,##0.00
+	Float                   // number shown as it is
+	Date                    // date shown as 2006/1/2
+	Month                   // date shown as 2006年1月
+)
+
+// Cell is a cell to write, Value is number in decimal if Type is number,
+// and "2006-01-02" or "2006-01-02T15:04:05" if Type is date,
+// Formula is excel formula without "=" such as "SUM(D2:F2)", and Value is its result
+type Cell struct {
+	Value   string
+	Type    CellType
+	Formula string
+}
+
+// Table is a sheet to write
+type Table struct {
+	Name   string
+	Hidden bool
+	Rows   [][]Cell
+}
+
+const manifestXML = `<?xml version="1.0" encoding="UTF-8"?>
+<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.2">
+ <manifest:file-entry manifest:full-path="/" manifest:version="1.2" manifest:media-type="` + mimeType + `"/>
+ <manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>
+</manifest:manifest>
+`
+
+const contentHead = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="` + nsOffice + `" xmlns:style="` + nsStyle + `" xmlns:text="` + nsText + `" xmlns:table="` + nsTable + `" xmlns:number="urn:oasis:names:tc:opendocument:xmlns:datastyle:1.0" office:version="1.2">
+<office:automatic-styles>
+<number:number-style style:name="N1"><number:number number:decimal-places="0" number:min-integer-digits="1" number:grouping="true"/></number:number-style>
+<number:number-style style:name="N2"><number:number number:decimal-places="2" number:min-decimal-places="2" number:min-integer-digits="1" number:grouping="true"/></number:number-style>
+<style:style style:name="ce1" style:family="table-cell" style:data-style-name="N1"/>
+<number:date-style style:name="N3"><number:year number:style="long"/><number:text>/</number:text><number:month/><number:text>/</number:text><number:day/></number:date-style>
+<number:date-style style:name="N4"><number:year number:style="long"/><number:text>年</number:text><number:month/><number:text>月</number:text></number:date-style>
+<style:style style:name="ce2" style:family="table-cell" style:data-style-name="N2"/>
+<style:style style:name="ce3" style:family="table-cell" style:data-style-name="N3"/>
+<style:style style:name="ce4" style:family="table-cell" style:data-style-name="N4"/>
+<style:style style:name="ta1" style:family="table"><style:table-properties table:display="true"/></style:style>
+<style:style style:name="ta2" style:family="table"><style:table-properties table:display="false"/></style:style>
+</office:automatic-styles>
+<office:body><office:spreadsheet>
+`
+
+const contentTail = `</office:spreadsheet></office:body></office:document-content>
+`
+
+// cell style of number and date types, number of other types is shown as it is
+var typeStyles = map[CellType]string{
+	Integer: "ce1",
+	Decimal: "ce2",
+	Date:    "ce3",
+	Month:   "ce4",
+}
+
+// Write write tables as ods file into w
+func Write(w io.Writer, tables []Table) error {
+	zw := zip.NewWriter(w)
+	// mimetype must be the first entry and not compressed
+	mime, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mime, mimeType); err != nil {
+		return err
+	}
+	manifest, err := zw.Create("META-INF/manifest.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(manifest, manifestXML); err != nil {
+		return err
+	}
+	content, err := zw.Create("content.xml")
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(content)
+	bw.WriteString(contentHead)
+	for _, table := range tables {
+		writeTable(bw, table)
+	}
+	bw.WriteString(contentTail)
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeTable write rows of table, the same cells or rows next to each other are written once with repeated count
+func writeTable(w *bufio.Writer, table Table) {
+	style := "ta1"
+	if table.Hidden {
+		style = "ta2"
+	}
+	w.WriteString(`<table:table table:name="` + escape(table.Name) + `" table:style-name="` + style + `">`)
+	w.WriteString(`<table:table-column/>`)
+	for i := 0; i < len(table.Rows); {
+		row := table.Rows[i]
+		n := 1
+		for i+n < len(table.Rows) && sameRow(row, table.Rows[i+n]) {
+			n++
+		}
+		i += n
+		if n > 1 {
+			w.WriteString(`<table:table-row table:number-rows-repeated="` + strconv.Itoa(n) + `">`)
+		} else {
+			w.WriteString(`<table:table-row>`)
+		}
+		for j := 0; j < len(row); {
+			m := 1
+			for j+m < len(row) && sameCell(row[j], row[j+m]) {
+				m++
+			}
+			writeCell(w, row[j], m)
+			j += m
+		}
+		if len(row) == 0 {
+			w.WriteString(`<table:table-cell/>`)
+		}
+		w.WriteString(`</table:table-row>`)
+	}
+	w.WriteString("</table:table>\n")
+}
+
+// sameCell check whether cells can be written as repeated, formula cells are not as references are relative
+func sameCell(a, b Cell) bool {
+	return a == b && a.Formula == ""
+}
+
+func sameRow(a, b []Cell) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameCell(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeCell write cell repeated n times, a number or date can not be parsed is written as text
+func writeCell(w *bufio.Writer, cell Cell, n int) {
+	attrs := ""
+	if n > 1 {
+		attrs += ` table:number-columns-repeated="` + strconv.Itoa(n) + `"`
+	}
+	if cell.Formula != "" {
+		attrs += ` table:formula="` + escape("of:="+odfFormula(cell.Formula)) + `"`
+	}
+	if cell.Value == "" {
+		w.WriteString(`<table:table-cell` + attrs + `/>`)
+		return
+	}
+	if value, text, ok := typedValue(cell); ok {
+		if style := typeStyles[cell.Type]; style != "" {
+			attrs += ` table:style-name="` + style + `"`
+		}
+		w.WriteString(`<table:table-cell` + attrs + value + `>`)
+		w.WriteString(`<text:p>` + escape(text) + `</text:p></table:table-cell>`)
+		return
+	}
+	w.WriteString(`<table:table-cell` + attrs + ` office:value-type="string">`)
+	for _, line := range strings.Split(cell.Value, "\n") {
+		w.WriteString(`<text:p>` + escape(line) + `</text:p>`)
+	}
+	w.WriteString(`</table:table-cell>`)
+}
+
+// typedValue get value attributes and text of number or date cell, false for text
+func typedValue(cell Cell) (string, string, bool) {
+	switch cell.Type {
+	case Integer, Decimal, Float:
+		if _, err := strconv.ParseFloat(cell.Value, 64); err != nil {
+			return "", "", false
+		}
+		return ` office:value-type="float" office:value="` + escape(cell.Value) + `"`, cell.Value, true
+	case Date, Month:
+		text := dateText(cell.Value, cell.Type)
+		if text == "" {
+			return "", "", false
+		}
+		return ` office:value-type="date" office:date-value="` + escape(cell.Value) + `"`, text, true
+	}
+	return "", "", false
+}
+
+// dateText get text of date value shown by type, empty if value is not a date
+func dateText(value string, typ CellType) string {
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		if t, err = time.Parse("2006-01-02T15:04:05", value); err != nil {
+			return ""
+		}
+	}
+	if typ == Month {
+		return t.Format("2006年1月")
+	}
+	return t.Format("2006/1/2")
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,110 @@
+// code for exit code and machine-readable summary of a run
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"excel/collect"
+	"os"
+)
+
+// exit codes
+const (
+	exitOK      = 0
+	exitConfig  = 1 // invalid arguments, config.ini or tasks
+	exitInput   = 2 // src or dst files can not be read or written
+	exitPartial = 3 // some tasks failed
+	exitFailed  = 4 // all tasks failed
+)
+
+var exitStatus = map[int]string{
+	exitOK:      "ok",
+	exitConfig:  "config_error",
+	exitInput:   "input_error",
+	exitPartial: "partial",
+	exitFailed:  "failed",
+}
+
+type summary struct {
+	Status   string        `json:"status"`
+	ExitCode int           `json:"exit_code"`
+	Error    string        `json:"error,omitempty"`
+	Tasks    []taskSummary `json:"tasks"`
+}
+
+type taskSummary struct {
+	Task        string   `json:"task"`
+	Status      string   `json:"status"` // ok or failed
+	Error       string   `json:"error,omitempty"`
+	Files       []string `json:"files"`
+	RowsRead    int      `json:"rows_read"`
+	RowsSkipped int      `json:"rows_skipped"`
+	RowsWritten int      `json:"rows_written"`
+	DurationMs  int64    `json:"duration_ms"`
+}
+
+// exitCode classify error of a run by results of tasks
+func exitCode(err error, results []collect.TaskResult) int {
+	if err == nil {
+		return exitOK
+	}
+	var runErr collect.RunError
+	if !errors.As(err, &runErr) {
+		return exitInput // failed before tasks run
+	}
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	switch {
+	case failed == 0:
+		return exitInput // tasks done but report or dst file not saved
+	case failed == len(results):
+		return exitFailed
+	default:
+		return exitPartial
+	}
+}
+
+func newSummary(code int, err error, results []collect.TaskResult) *summary {
+	s := &summary{
+		Status:   exitStatus[code],
+		ExitCode: code,
+		Tasks:    make([]taskSummary, 0, len(results)),
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	for _, result := range results {
+		task := taskSummary{
+			Task:        result.Task,
+			Status:      "ok",
+			Files:       result.Files,
+			RowsRead:    result.RowsRead,
+			RowsSkipped: result.RowsSkipped,
+			RowsWritten: result.RowsWritten,
+			DurationMs:  result.Duration.Milliseconds(),
+		}
+		if task.Files == nil {
+			task.Files = []string{}
+		}
+		if result.Err != nil {
+			task.Status = "failed"
+			task.Error = result.Err.Error()
+		}
+		s.Tasks = append(s.Tasks, task)
+	}
+	return s
+}
+
+// write summary as json into path
+func (s *summary) write(path string) error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
@@ -0,0 +1,454 @@
+package xls
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"unicode/utf16"
+)
+
+// record types of BIFF8
+const (
+	recFormula    = 0x0006
+	recEOF        = 0x000A
+	recFilePass   = 0x002F
+	recDate1904   = 0x0022
+	recContinue   = 0x003C
+	recBoundSheet = 0x0085
+	recMulRK      = 0x00BD
+	recRString    = 0x00D6
+	recXF         = 0x00E0
+	recSST        = 0x00FC
+	recLabelSST   = 0x00FD
+	recNumber     = 0x0203
+	recLabel      = 0x0204
+	recBoolErr    = 0x0205
+	recString     = 0x0207
+	recRK         = 0x027E
+	recFormat     = 0x041E
+	recBOF        = 0x0809
+)
+
+const (
+	biff8Version   = 0x0600
+	bofGlobals     = 0x0005
+	bofWorksheet   = 0x0010
+	sheetWorksheet = 0
+)
+
+var errTruncated = errors.New("xls: record truncated")
+
+var errorValues = map[byte]string{
+	0x00: "#NULL!",
+	0x07: "#DIV/0!",
+	0x0F: "#VALUE!",
+	0x17: "#REF!",
+	0x1D: "#NAME?",
+	0x24: "#NUM!",
+	0x2A: "#N/A",
+}
+
+type record struct {
+	id   uint16
+	data []byte
+}
+
+// readRecord read record at offset of stream, return offset of next record
+func readRecord(stream []byte, offset int) (record, int, error) {
+	if offset+4 > len(stream) {
+		return record{}, 0, errTruncated
+	}
+	id := binary.LittleEndian.Uint16(stream[offset:])
+	size := int(binary.LittleEndian.Uint16(stream[offset+2:]))
+	end := offset + 4 + size
+	if end > len(stream) {
+		return record{}, 0, errTruncated
+	}
+	return record{id: id, data: stream[offset+4 : end]}, end, nil
+}
+
+func (wb *Workbook) parseGlobals(stream []byte) error {
+	rec, offset, err := readRecord(stream, 0)
+	if err != nil {
+		return err
+	}
+	if rec.id != recBOF || len(rec.data) < 4 ||
+		binary.LittleEndian.Uint16(rec.data) != biff8Version || binary.LittleEndian.Uint16(rec.data[2:]) != bofGlobals {
+		return ErrNotBIFF8
+	}
+	for offset < len(stream) {
+		if rec, offset, err = readRecord(stream, offset); err != nil {
+			return err
+		}
+		switch rec.id {
+		case recEOF:
+			return nil
+		case recFilePass:
+			return ErrEncrypted
+		case recDate1904:
+			wb.date1904 = len(rec.data) >= 2 && binary.LittleEndian.Uint16(rec.data) == 1
+		case recFormat:
+			if len(rec.data) < 2 {
+				return errTruncated
+			}
+			code, _ := readString(rec.data[2:], 2)
+			wb.formats[binary.LittleEndian.Uint16(rec.data)] = code
+		case recXF:
+			if len(rec.data) < 4 {
+				return errTruncated
+			}
+			wb.xfs = append(wb.xfs, binary.LittleEndian.Uint16(rec.data[2:]))
+		case recBoundSheet:
+			if len(rec.data) < 8 {
+				return errTruncated
+			}
+			if rec.data[5] != sheetWorksheet {
+				continue // chart or macro sheet
+			}
+			name, _ := readString(rec.data[6:], 1)
+			wb.sheets = append(wb.sheets, &Sheet{
+				Name:    name,
+				Visible: rec.data[4]&0x03 == 0,
+				offset:  binary.LittleEndian.Uint32(rec.data),
+			})
+		case recSST:
+			segments := [][]byte{rec.data}
+			for offset < len(stream) {
+				next, nextOffset, err := readRecord(stream, offset)
+				if err != nil || next.id != recContinue {
+					break
+				}
+				segments = append(segments, next.data)
+				offset = nextOffset
+			}
+			if wb.sst, err = readSST(segments); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (wb *Workbook) parseSheet(stream []byte, sheet *Sheet) error {
+	offset := int(sheet.offset)
+	rec, offset, err := readRecord(stream, offset)
+	if err != nil {
+		return err
+	}
+	if rec.id != recBOF || len(rec.data) < 4 || binary.LittleEndian.Uint16(rec.data[2:]) != bofWorksheet {
+		return ErrNotBIFF8
+	}
+	depth := 1 // embedded charts have their own BOF and EOF
+	formulaRow, formulaCol, formulaXF := -1, -1, 0
+	for offset < len(stream) && depth > 0 {
+		if rec, offset, err = readRecord(stream, offset); err != nil {
+			return err
+		}
+		data := rec.data
+		switch rec.id {
+		case recBOF:
+			depth++
+			continue
+		case recEOF:
+			depth--
+			continue
+		}
+		if depth > 1 {
+			continue
+		}
+		switch rec.id {
+		case recNumber:
+			if len(data) < 14 {
+				return errTruncated
+			}
+			row, col, xf := cellHeader(data)
+			wb.setNumber(sheet, row, col, xf, math.Float64frombits(binary.LittleEndian.Uint64(data[6:])))
+		case recRK:
+			if len(data) < 10 {
+				return errTruncated
+			}
+			row, col, xf := cellHeader(data)
+			wb.setNumber(sheet, row, col, xf, rkValue(binary.LittleEndian.Uint32(data[6:])))
+		case recMulRK:
+			if len(data) < 6 {
+				return errTruncated
+			}
+			row := int(binary.LittleEndian.Uint16(data))
+			col := int(binary.LittleEndian.Uint16(data[2:]))
+			for i := 4; i+6 <= len(data)-2; i += 6 {
+				xf := binary.LittleEndian.Uint16(data[i:])
+				wb.setNumber(sheet, row, col, xf, rkValue(binary.LittleEndian.Uint32(data[i+2:])))
+				col++
+			}
+		case recLabelSST:
+			if len(data) < 10 {
+				return errTruncated
+			}
+			row, col, xf := cellHeader(data)
+			if index := int(binary.LittleEndian.Uint32(data[6:])); index < len(wb.sst) {
+				sheet.setCell(row, col, cell{value: wb.sst[index], numFmt: wb.numFmt(xf)})
+			}
+		case recLabel, recRString:
+			if len(data) < 8 {
+				return errTruncated
+			}
+			row, col, xf := cellHeader(data)
+			value, _ := readString(data[6:], 2)
+			sheet.setCell(row, col, cell{value: value, numFmt: wb.numFmt(xf)})
+		case recBoolErr:
+			if len(data) < 8 {
+				return errTruncated
+			}
+			row, col, xf := cellHeader(data)
+			sheet.setCell(row, col, cell{value: boolErrValue(data[6], data[7] == 1), numFmt: wb.numFmt(xf)})
+		case recFormula:
+			if len(data) < 14 {
+				return errTruncated
+			}
+			row, col, xf := cellHeader(data)
+			result := data[6:14]
+			if result[6] != 0xFF || result[7] != 0xFF {
+				wb.setNumber(sheet, row, col, xf, math.Float64frombits(binary.LittleEndian.Uint64(result)))
+				continue
+			}
+			switch result[0] {
+			case 0: // string in the next STRING record
+				formulaRow, formulaCol, formulaXF = row, col, int(xf)
+			case 1:
+				sheet.setCell(row, col, cell{value: boolErrValue(result[2], false), numFmt: wb.numFmt(xf)})
+			case 2:
+				sheet.setCell(row, col, cell{value: boolErrValue(result[2], true), numFmt: wb.numFmt(xf)})
+			}
+		case recString:
+			if formulaRow < 0 || len(data) < 3 {
+				continue
+			}
+			value, _ := readString(data, 2)
+			sheet.setCell(formulaRow, formulaCol, cell{value: value, numFmt: wb.numFmt(uint16(formulaXF))})
+			formulaRow, formulaCol = -1, -1
+		}
+	}
+	return nil
+}
+
+func cellHeader(data []byte) (row, col int, xf uint16) {
+	return int(binary.LittleEndian.Uint16(data)), int(binary.LittleEndian.Uint16(data[2:])), binary.LittleEndian.Uint16(data[4:])
+}
+
+// numFmt return number format id of cell format xf
+func (wb *Workbook) numFmt(xf uint16) int {
+	if int(xf) >= len(wb.xfs) {
+		return 0
+	}
+	return int(wb.xfs[xf])
+}
+
+func (wb *Workbook) setNumber(sheet *Sheet, row, col int, xf uint16, v float64) {
+	numFmt := wb.numFmt(xf)
+	sheet.setCell(row, col, cell{value: formatNumber(v, numFmt, wb.formats[uint16(numFmt)], wb.date1904), numFmt: numFmt})
+}
+
+// rkValue decode RK number, which is a float with low 34 bits dropped or an integer, may be multiplied by 100
+func rkValue(rk uint32) float64 {
+	var v float64
+	if rk&0x02 != 0 {
+		v = float64(int32(rk) >> 2)
+	} else {
+		v = math.Float64frombits(uint64(rk&0xFFFFFFFC) << 32)
+	}
+	if rk&0x01 != 0 {
+		v /= 100
+	}
+	return v
+}
+
+func boolErrValue(v byte, isErr bool) string {
+	if isErr {
+		return errorValues[v]
+	}
+	if v != 0 {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// readString read XLUnicodeString whose length takes lenSize bytes, return string and bytes used
+func readString(data []byte, lenSize int) (string, int) {
+	if len(data) < lenSize+1 {
+		return "", len(data)
+	}
+	var cch int
+	if lenSize == 1 {
+		cch = int(data[0])
+	} else {
+		cch = int(binary.LittleEndian.Uint16(data))
+	}
+	flags := data[lenSize]
+	pos := lenSize + 1
+	runs, extSize := 0, 0
+	if flags&0x08 != 0 && pos+2 <= len(data) {
+		runs = int(binary.LittleEndian.Uint16(data[pos:]))
+		pos += 2
+	}
+	if flags&0x04 != 0 && pos+4 <= len(data) {
+		extSize = int(binary.LittleEndian.Uint32(data[pos:]))
+		pos += 4
+	}
+	s, n := decodeChars(data[pos:], cch, flags&0x01 != 0)
+	return s, pos + n + runs*4 + extSize
+}
+
+// decodeChars decode cch chars, compressed chars take one byte and others take two bytes of UTF-16LE
+func decodeChars(data []byte, cch int, highByte bool) (string, int) {
+	if !highByte {
+		if cch > len(data) {
+			cch = len(data)
+		}
+		runes := make([]rune, cch)
+		for i := 0; i < cch; i++ {
+			runes[i] = rune(data[i])
+		}
+		return string(runes), cch
+	}
+	if cch*2 > len(data) {
+		cch = len(data) / 2
+	}
+	units := make([]uint16, cch)
+	for i := 0; i < cch; i++ {
+		units[i] = binary.LittleEndian.Uint16(data[i*2:])
+	}
+	return string(utf16.Decode(units)), cch * 2
+}
+
+// sstReader read bytes of SST record and its CONTINUE records as one stream,
+// except that chars of string continued in next record start with a new flags byte
+type sstReader struct {
+	segments [][]byte
+	seg, pos int
+}
+
+func (r *sstReader) next() bool {
+	for r.seg < len(r.segments) && r.pos >= len(r.segments[r.seg]) {
+		r.seg++
+		r.pos = 0
+	}
+	return r.seg < len(r.segments)
+}
+
+// remaining return number of bytes not read yet
+func (r *sstReader) remaining() int {
+	n := 0
+	for i := r.seg; i < len(r.segments); i++ {
+		n += len(r.segments[i])
+	}
+	if r.seg < len(r.segments) {
+		n -= r.pos
+	}
+	return n
+}
+
+// bytes read n bytes, n comes from the file so it is not trusted to allocate
+func (r *sstReader) bytes(n int) ([]byte, error) {
+	out := make([]byte, 0, min(n, r.remaining()))
+	for len(out) < n {
+		if !r.next() {
+			return nil, errTruncated
+		}
+		seg := r.segments[r.seg]
+		take := min(n-len(out), len(seg)-r.pos)
+		out = append(out, seg[r.pos:r.pos+take]...)
+		r.pos += take
+	}
+	return out, nil
+}
+
+func (r *sstReader) uint16() (int, error) {
+	b, err := r.bytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.LittleEndian.Uint16(b)), nil
+}
+
+func (r *sstReader) uint32() (int, error) {
+	b, err := r.bytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.LittleEndian.Uint32(b)), nil
+}
+
+// chars read cch chars which may be split by record boundary
+func (r *sstReader) chars(cch int, highByte bool) (string, error) {
+	units := make([]uint16, 0, cch)
+	for len(units) < cch {
+		if r.seg >= len(r.segments) {
+			return "", errTruncated
+		}
+		seg := r.segments[r.seg]
+		if r.pos >= len(seg) {
+			// chars continued in next record, which starts with its own flags
+			r.seg++
+			if r.seg >= len(r.segments) || len(r.segments[r.seg]) == 0 {
+				return "", errTruncated
+			}
+			highByte = r.segments[r.seg][0]&0x01 != 0
+			r.pos = 1
+			continue
+		}
+		if highByte {
+			if r.pos+2 > len(seg) {
+				return "", errTruncated
+			}
+			units = append(units, binary.LittleEndian.Uint16(seg[r.pos:]))
+			r.pos += 2
+		} else {
+			units = append(units, uint16(seg[r.pos]))
+			r.pos++
+		}
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+func readSST(segments [][]byte) ([]string, error) {
+	r := &sstReader{segments: segments}
+	if _, err := r.bytes(4); err != nil { // total count of strings in workbook
+		return nil, err
+	}
+	count, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	// count comes from the file, each string takes at least 3 bytes of cch and flags
+	sst := make([]string, 0, min(count, r.remaining()/3))
+	for i := 0; i < count; i++ {
+		cch, err := r.uint16()
+		if err != nil {
+			return sst, nil // some writers declare more strings than written
+		}
+		flags, err := r.bytes(1)
+		if err != nil {
+			return nil, err
+		}
+		runs, extSize := 0, 0
+		if flags[0]&0x08 != 0 {
+			if runs, err = r.uint16(); err != nil {
+				return nil, err
+			}
+		}
+		if flags[0]&0x04 != 0 {
+			if extSize, err = r.uint32(); err != nil {
+				return nil, err
+			}
+		}
+		s, err := r.chars(cch, flags[0]&0x01 != 0)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := r.bytes(runs*4 + extSize); err != nil {
+			return nil, err
+		}
+		sst = append(sst, s)
+	}
+	return sst, nil
+}
@@ -0,0 +1,106 @@
+package xls
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// layouts of built-in date and time formats, the same as excelize renders for xlsx
+var builtInLayouts = map[int]string{
+	14: "01-02-06",     // mm-dd-yy
+	15: "02-Jan-06",    // d-mmm-yy
+	16: "02-Jan",       // d-mmm
+	17: "Jan-06",       // mmm-yy
+	18: "3:04 PM",      // h:mm AM/PM
+	19: "3:04:05 PM",   // h:mm:ss AM/PM
+	20: "15:04",        // h:mm
+	21: "15:04:05",     // h:mm:ss
+	22: "1/2/06 15:04", // m/d/yy h:mm
+	45: "04:05",        // mm:ss
+	47: "04:05.0",      // mm:ss.0
+}
+
+// chinese locale built-in formats which are dates, such as yyyy"年"m"月"
+func isCNDateFormat(id int) bool {
+	return (id >= 27 && id <= 36) || (id >= 50 && id <= 58)
+}
+
+// formatNumber render number by format id, and format code if it is custom
+func formatNumber(v float64, id int, code string, date1904 bool) string {
+	if layout, ok := builtInLayouts[id]; ok {
+		return serialToTime(v, date1904).Format(layout)
+	}
+	switch id {
+	case 1, 3:
+		return strconv.FormatInt(int64(math.Round(v)), 10)
+	case 2, 4:
+		return fmt.Sprintf("%.2f", v)
+	case 9:
+		return fmt.Sprintf("%d%%", int64(math.Round(v*100)))
+	case 10:
+		return fmt.Sprintf("%.2f%%", v*100)
+	}
+	if isCNDateFormat(id) {
+		return serialToTime(v, date1904).Format("2006/1/2")
+	}
+	if code != "" {
+		date, clock := dateTokens(code)
+		switch {
+		case date && clock:
+			return serialToTime(v, date1904).Format("2006/1/2 15:04:05")
+		case date:
+			return serialToTime(v, date1904).Format("2006/1/2")
+		case clock:
+			return serialToTime(v, date1904).Format("15:04:05")
+		}
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// dateTokens check whether format code has date or time tokens, quoted text and brackets are ignored
+func dateTokens(code string) (date, clock bool) {
+	code = strings.ToLower(code)
+	if i := strings.Index(code, ";"); i >= 0 {
+		code = code[:i] // the format of positive numbers
+	}
+	quoted, bracket := false, false
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '\\':
+			i++ // escaped char
+		case c == '[':
+			bracket = true
+		case c == ']':
+			bracket = false
+		case bracket:
+		case c == 'y' || c == 'd':
+			date = true
+		case c == 'h' || c == 's':
+			clock = true
+		case c == 'm':
+			date = date || !clock // "m" after "h" is minute
+		}
+	}
+	return date, clock
+}
+
+// serialToTime convert excel serial date to time,
+// 1900 date system counts 1900-02-29 which does not exist
+func serialToTime(v float64, date1904 bool) time.Time {
+	base := time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+	if date1904 {
+		base = time.Date(1904, time.January, 1, 0, 0, 0, 0, time.UTC)
+	} else if v < 61 {
+		base = base.AddDate(0, 0, 1)
+	}
+	days := math.Floor(v)
+	seconds := math.Round((v - days) * 86400)
+	return base.AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+}
@@ -0,0 +1,380 @@
+package xls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+)
+
+// samples in testdata are written by the code below, run "go test ./xls -update" to write them again
+var update = flag.Bool("update", false, "write xls samples in testdata")
+
+// record types only written by samples
+const (
+	recRow       = 0x0208
+	recDimension = 0x0200
+	recWindow2   = 0x023E
+	recBlank     = 0x0201
+)
+
+const (
+	bofChart   = 0x0020
+	sheetChart = 2
+)
+
+type sampleSheet struct {
+	name    string
+	state   byte // 0 visible, 1 hidden, 2 very hidden
+	kind    byte // sheetWorksheet or sheetChart
+	records [][]byte
+}
+
+type sampleBook struct {
+	date1904 bool
+	formats  map[uint16]string
+	xfs      []uint16 // number format id of each xf
+	sst      [][]byte // data of SST record and its CONTINUE records
+	sheets   []sampleSheet
+}
+
+func u16(v int) []byte {
+	return binary.LittleEndian.AppendUint16(nil, uint16(v))
+}
+
+func u32(v int) []byte {
+	return binary.LittleEndian.AppendUint32(nil, uint32(v))
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func rec(id uint16, parts ...[]byte) []byte {
+	data := join(parts...)
+	return join(u16(int(id)), u16(len(data)), data)
+}
+
+func bof(dt int) []byte {
+	return rec(recBOF, u16(biff8Version), u16(dt), u16(0x0DBB), u16(0x07CC), u32(0x000100C1), u32(0x00000006))
+}
+
+// chars get chars of string, compressed if all chars are latin-1
+func chars(s string) (flags byte, data []byte) {
+	units := utf16.Encode([]rune(s))
+	for _, u := range units {
+		if u > 0xFF {
+			flags = 1
+		}
+	}
+	for _, u := range units {
+		if flags == 1 {
+			data = append(data, u16(int(u))...)
+		} else {
+			data = append(data, byte(u))
+		}
+	}
+	return flags, data
+}
+
+// str get XLUnicodeString whose length takes lenSize bytes
+func str(s string, lenSize int) []byte {
+	flags, data := chars(s)
+	cch := []byte{byte(len([]rune(s)))}
+	if lenSize == 2 {
+		cch = u16(len(utf16.Encode([]rune(s))))
+	}
+	return join(cch, []byte{flags}, data)
+}
+
+func cellAt(row, col, xf int) []byte {
+	return join(u16(row), u16(col), u16(xf))
+}
+
+func number(row, col, xf int, v float64) []byte {
+	return rec(recNumber, cellAt(row, col, xf), binary.LittleEndian.AppendUint64(nil, math.Float64bits(v)))
+}
+
+func label(row, col, xf int, s string) []byte {
+	return rec(recLabel, cellAt(row, col, xf), str(s, 2))
+}
+
+func labelSST(row, col, xf, index int) []byte {
+	return rec(recLabelSST, cellAt(row, col, xf), u32(index))
+}
+
+// rkInt get RK of integer, multiplied by 100 if div100
+func rkInt(v int, div100 bool) int {
+	rk := v<<2 | 0x02
+	if div100 {
+		rk |= 0x01
+	}
+	return rk
+}
+
+// rkFloat get RK of float whose low 34 bits are zero, such as 1.5
+func rkFloat(v float64) int {
+	return int(math.Float64bits(v) >> 32 &^ 0x03)
+}
+
+func rk(row, col, xf, value int) []byte {
+	return rec(recRK, cellAt(row, col, xf), u32(value))
+}
+
+// mulRK get MULRK of cells in row from col, each is xf and RK
+func mulRK(row, col int, cells ...[2]int) []byte {
+	data := join(u16(row), u16(col))
+	for _, c := range cells {
+		data = join(data, u16(c[0]), u32(c[1]))
+	}
+	return rec(recMulRK, data, u16(col+len(cells)-1))
+}
+
+// formula get FORMULA of cached result, a string result is in STRING record after it,
+// the parsed expression is left empty as only results are read
+func formula(row, col, xf int, result []byte) []byte {
+	return rec(recFormula, cellAt(row, col, xf), result, u16(0), u32(0), u16(0))
+}
+
+func numberResult(v float64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, math.Float64bits(v))
+}
+
+// specialResult get result of string (0), bool (1), error (2) or empty string (3)
+func specialResult(kind, value byte) []byte {
+	return []byte{kind, 0, value, 0, 0, 0, 0xFF, 0xFF}
+}
+
+// stream write workbook stream, positions of sheets are set after globals are written
+func (b sampleBook) stream() []byte {
+	globals := [][]byte{bof(bofGlobals)}
+	if b.date1904 {
+		globals = append(globals, rec(recDate1904, u16(1)))
+	}
+	for id := uint16(0); id < 0x200; id++ {
+		if code, ok := b.formats[id]; ok {
+			globals = append(globals, rec(recFormat, u16(int(id)), str(code, 2)))
+		}
+	}
+	for _, numFmt := range b.xfs {
+		globals = append(globals, rec(recXF, u16(0), u16(int(numFmt)), make([]byte, 16)))
+	}
+	sheetAt := len(globals)
+	for _, sheet := range b.sheets {
+		globals = append(globals, rec(recBoundSheet, u32(0), []byte{sheet.state, sheet.kind}, str(sheet.name, 1)))
+	}
+	for i, data := range b.sst {
+		id := uint16(recSST)
+		if i > 0 {
+			id = recContinue
+		}
+		globals = append(globals, rec(id, data))
+	}
+	globals = append(globals, rec(recEOF))
+
+	offset := len(join(globals...))
+	substreams := make([]byte, 0)
+	for i, sheet := range b.sheets {
+		binary.LittleEndian.PutUint32(globals[sheetAt+i][4:], uint32(offset+len(substreams)))
+		dt := bofWorksheet
+		if sheet.kind == sheetChart {
+			dt = bofChart
+		}
+		substream := join(bof(dt), rec(recWindow2, u16(0x06B6), make([]byte, 16)))
+		for _, r := range sheet.records {
+			substream = append(substream, r...)
+		}
+		substreams = append(substreams, join(substream, rec(recEOF))...)
+	}
+	return join(join(globals...), substreams)
+}
+
+// compound file of version 3 with 512 bytes sectors: header, one FAT sector, one directory sector,
+// and the workbook stream, which is at least 4096 bytes so it is not in mini stream
+const (
+	sectorSize = 512
+	freeSect   = 0xFFFFFFFF
+	endOfChain = 0xFFFFFFFE
+	fatSect    = 0xFFFFFFFD
+	noStream   = 0xFFFFFFFF
+)
+
+func dirEntry(name string, kind byte, child, start, size uint32) []byte {
+	entry := make([]byte, 128)
+	units := utf16.Encode([]rune(name))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(entry[i*2:], u)
+	}
+	if name != "" {
+		binary.LittleEndian.PutUint16(entry[64:], uint16(len(units)*2+2))
+	}
+	entry[66], entry[67] = kind, 1 // black
+	binary.LittleEndian.PutUint32(entry[68:], noStream)
+	binary.LittleEndian.PutUint32(entry[72:], noStream)
+	binary.LittleEndian.PutUint32(entry[76:], child)
+	binary.LittleEndian.PutUint32(entry[116:], start)
+	binary.LittleEndian.PutUint32(entry[120:], size)
+	return entry
+}
+
+func compoundFile(stream []byte) []byte {
+	if len(stream) < 4096 {
+		stream = append(stream, make([]byte, 4096-len(stream))...)
+	}
+	sectors := (len(stream) + sectorSize - 1) / sectorSize
+	if sectors+2 > sectorSize/4 {
+		panic("sample too large for one FAT sector")
+	}
+
+	header := make([]byte, sectorSize)
+	copy(header, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1})
+	binary.LittleEndian.PutUint16(header[24:], 0x003E)
+	binary.LittleEndian.PutUint16(header[26:], 3)
+	binary.LittleEndian.PutUint16(header[28:], 0xFFFE)
+	binary.LittleEndian.PutUint16(header[30:], 9) // sector shift
+	binary.LittleEndian.PutUint16(header[32:], 6) // mini sector shift
+	binary.LittleEndian.PutUint32(header[44:], 1) // FAT sectors
+	binary.LittleEndian.PutUint32(header[48:], 1) // first directory sector
+	binary.LittleEndian.PutUint32(header[56:], 4096)
+	binary.LittleEndian.PutUint32(header[60:], endOfChain) // no mini FAT
+	binary.LittleEndian.PutUint32(header[68:], endOfChain) // no more DIFAT
+	for i := 76; i < sectorSize; i += 4 {
+		binary.LittleEndian.PutUint32(header[i:], freeSect)
+	}
+	binary.LittleEndian.PutUint32(header[76:], 0) // FAT in sector 0
+
+	fat := make([]byte, sectorSize)
+	for i := 0; i < sectorSize/4; i++ {
+		next := uint32(freeSect)
+		switch {
+		case i == 0:
+			next = fatSect
+		case i == 1 || i == sectors+1:
+			next = endOfChain
+		case i < sectors+1:
+			next = uint32(i + 1)
+		}
+		binary.LittleEndian.PutUint32(fat[i*4:], next)
+	}
+
+	dir := join(
+		dirEntry("Root Entry", 5, 1, endOfChain, 0),
+		dirEntry("Workbook", 2, noStream, 2, uint32(len(stream))),
+		dirEntry("", 0, noStream, 0, 0),
+		dirEntry("", 0, noStream, 0, 0),
+	)
+	padded := append(append([]byte{}, stream...), make([]byte, sectors*sectorSize-len(stream))...)
+	return join(header, fat, dir, padded)
+}
+
+// samples of features read, by file name in testdata
+var samples = map[string]sampleBook{
+	// shared strings split by CONTINUE, between strings and in chars of a string,
+	// the continued chars of "混合..." are in UTF-16 though the first part is compressed
+	"sst.xls": {
+		xfs: []uint16{0},
+		sst: [][]byte{
+			join(u32(6), u32(5), str("渠道", 2), str("abc", 2), u16(7), []byte{0}, []byte("mix")),
+			join([]byte{1}, utf16Bytes("混合文本"), str("spans record", 2)),
+			join(u16(4), []byte{0x08}, u16(1), []byte("rich"), make([]byte, 4)),
+		},
+		sheets: []sampleSheet{{name: "Sheet1", records: [][]byte{
+			labelSST(0, 0, 0, 0), labelSST(0, 1, 0, 1), labelSST(0, 2, 0, 2),
+			labelSST(1, 0, 0, 3), labelSST(1, 1, 0, 4), label(1, 2, 0, "标签"),
+		}}},
+	},
+	// numbers in RK, MULRK and NUMBER, cached results of formulas, and an embedded chart whose records are skipped
+	"cells.xls": {
+		xfs: []uint16{0, 0, 2},
+		sheets: []sampleSheet{{name: "数据", records: [][]byte{
+			rec(recDimension, u32(0), u32(6), u16(0), u16(5), u16(0)),
+			rec(recRow, u16(0), u16(0), u16(5), u16(0xFF), u32(0), u32(0x100)),
+			mulRK(0, 0, [2]int{0, rkInt(12, false)}, [2]int{0, rkInt(1234, true)}, [2]int{0, rkFloat(1.5)}, [2]int{2, rkInt(-3, false)}),
+			rk(1, 0, 0, rkInt(100, false)),
+			number(1, 1, 0, 0.1),
+			rec(recBlank, cellAt(1, 2, 0)),
+			number(1, 3, 2, 1234.5),
+			formula(2, 0, 0, numberResult(42)),
+			formula(2, 1, 0, specialResult(0, 0)),
+			rec(recString, str("公式文本", 2)),
+			formula(2, 2, 0, specialResult(1, 1)),
+			formula(2, 3, 0, specialResult(2, 0x07)),
+			formula(2, 4, 0, specialResult(3, 0)),
+			bof(bofChart),
+			number(0, 0, 0, 999),
+			rec(recEOF),
+			label(3, 0, 0, "图表之后"),
+		}}},
+	},
+	// visible, hidden and very hidden worksheets, and a chart sheet which is not a worksheet
+	"sheets.xls": {
+		xfs: []uint16{0},
+		sheets: []sampleSheet{
+			{name: "显示", records: [][]byte{label(0, 0, 0, "a")}},
+			{name: "隐藏", state: 1, records: [][]byte{label(0, 0, 0, "b")}},
+			{name: "VeryHidden", state: 2, records: [][]byte{label(0, 0, 0, "c")}},
+			{name: "Chart1", kind: sheetChart},
+		},
+	},
+	// built-in, chinese and custom date formats, and number formats
+	"dates.xls": {
+		formats: map[uint16]string{
+			164: `yyyy"年"m"月"d"日"`,
+			165: `h:mm:ss`,
+			166: `yyyy/m/d h:mm`,
+			167: `[Red]0.00;\-0.00`,
+		},
+		xfs: []uint16{0, 14, 22, 31, 164, 165, 166, 4, 10, 167, 17},
+		sheets: []sampleSheet{{name: "日期", records: [][]byte{
+			number(0, 0, 1, 44451),
+			number(0, 1, 2, 44451.5),
+			number(0, 2, 3, 44451),
+			number(0, 3, 4, 44451),
+			number(0, 4, 5, 0.75),
+			number(0, 5, 6, 44451.25),
+			number(0, 6, 7, 1234.5),
+			number(0, 7, 8, 0.1234),
+			number(0, 8, 9, 3.14159),
+			number(0, 9, 10, 44451),
+			number(0, 10, 1, 59),
+			number(0, 11, 1, 61),
+		}}},
+	},
+	// 1904 date system of excel for mac
+	"date1904.xls": {
+		date1904: true,
+		xfs:      []uint16{0, 14},
+		sheets:   []sampleSheet{{name: "Sheet1", records: [][]byte{number(0, 0, 1, 42989)}}},
+	},
+}
+
+func utf16Bytes(s string) []byte {
+	data := make([]byte, 0)
+	for _, u := range utf16.Encode([]rune(s)) {
+		data = append(data, u16(int(u))...)
+	}
+	return data
+}
+
+// readSample read sample in testdata, written again first if -update
+func readSample(t *testing.T, name string) []byte {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, compoundFile(samples[name].stream()), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
@@ -0,0 +1,136 @@
+// Package xls read legacy excel 97-2003 workbooks (BIFF8) in compound file,
+// cell values are rendered as text by number format, the same way as excelize does for xlsx
+
+package xls
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/richardlehane/mscfb"
+	"io"
+)
+
+var (
+	ErrNotBIFF8   = errors.New("xls: not a BIFF8 workbook, only excel 97-2003 is supported")
+	ErrEncrypted  = errors.New("xls: workbook is encrypted")
+	ErrNoWorkbook = errors.New("xls: no workbook stream in file")
+)
+
+// Workbook is a parsed xls file
+type Workbook struct {
+	sheets   []*Sheet
+	date1904 bool
+	formats  map[uint16]string // custom number format id, format code
+	xfs      []uint16          // number format id of each cell format
+	sst      []string          // shared strings
+}
+
+// Sheet is a worksheet of workbook
+type Sheet struct {
+	Name    string
+	Visible bool
+	offset  uint32   // position of sheet BOF in workbook stream
+	rows    [][]cell // cells by row and col, from zero
+}
+
+type cell struct {
+	value  string // rendered value, empty for blank cell
+	numFmt int    // number format id
+}
+
+// Open read xls file from r
+func Open(r io.ReaderAt) (*Workbook, error) {
+	doc, err := mscfb.New(r)
+	if err != nil {
+		return nil, fmt.Errorf("xls: %w", err)
+	}
+	var stream []byte
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		switch entry.Name {
+		case "Workbook":
+			if stream, err = io.ReadAll(entry); err != nil {
+				return nil, fmt.Errorf("xls: %w", err)
+			}
+		case "Book":
+			return nil, ErrNotBIFF8 // excel 5.0/95
+		}
+		if stream != nil {
+			break
+		}
+	}
+	if stream == nil {
+		return nil, ErrNoWorkbook
+	}
+
+	wb := &Workbook{formats: make(map[uint16]string)}
+	if err := wb.parseGlobals(stream); err != nil {
+		return nil, err
+	}
+	for _, sheet := range wb.sheets {
+		if err := wb.parseSheet(stream, sheet); err != nil {
+			return nil, fmt.Errorf("xls: sheet %s: %w", sheet.Name, err)
+		}
+	}
+	return wb, nil
+}
+
+// OpenBytes read xls file from content
+func OpenBytes(content []byte) (*Workbook, error) {
+	return Open(bytes.NewReader(content))
+}
+
+// Sheets return worksheets in order of workbook, charts and macros are not included
+func (wb *Workbook) Sheets() []*Sheet {
+	return wb.sheets
+}
+
+// Sheet return worksheet of name, nil if not found
+func (wb *Workbook) Sheet(name string) *Sheet {
+	for _, sheet := range wb.sheets {
+		if sheet.Name == name {
+			return sheet
+		}
+	}
+	return nil
+}
+
+// Rows return rendered values of each row, trailing blank cells are trimmed and blank row is nil
+func (s *Sheet) Rows() [][]string {
+	rows := make([][]string, len(s.rows))
+	for i, cells := range s.rows {
+		last := -1
+		for col, c := range cells {
+			if c.value != "" {
+				last = col
+			}
+		}
+		if last < 0 {
+			continue
+		}
+		values := make([]string, last+1)
+		for col := 0; col <= last; col++ {
+			values[col] = cells[col].value
+		}
+		rows[i] = values
+	}
+	return rows
+}
+
+// NumFmt return built-in or custom number format id of cell, row and col start from one
+func (s *Sheet) NumFmt(col, row int) int {
+	if row < 1 || row > len(s.rows) || col < 1 || col > len(s.rows[row-1]) {
+		return 0
+	}
+	return s.rows[row-1][col-1].numFmt
+}
+
+func (s *Sheet) setCell(row, col int, c cell) {
+	for len(s.rows) <= row {
+		s.rows = append(s.rows, nil)
+	}
+	for len(s.rows[row]) <= col {
+		s.rows[row] = append(s.rows[row], cell{})
+	}
+	s.rows[row][col] = c
+}
@@ -0,0 +1,143 @@
+package xls
+
+import (
+	"reflect"
+	"testing"
+)
+
+func openSample(t *testing.T, name string) *Workbook {
+	t.Helper()
+	wb, err := OpenBytes(readSample(t, name))
+	if err != nil {
+		t.Fatalf("open %s: %v", name, err)
+	}
+	return wb
+}
+
+func TestSST(t *testing.T) {
+	wb := openSample(t, "sst.xls")
+	want := [][]string{
+		{"渠道", "abc", "mix混合文本"},
+		{"spans record", "rich", "标签"},
+	}
+	if got := wb.Sheet("Sheet1").Rows(); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+}
+
+func TestCells(t *testing.T) {
+	wb := openSample(t, "cells.xls")
+	sheet := wb.Sheet("数据")
+	if sheet == nil {
+		t.Fatal("no sheet 数据")
+	}
+	want := [][]string{
+		{"12", "12.34", "1.5", "-3.00"},
+		{"100", "0.1", "", "1234.50"},
+		{"42", "公式文本", "TRUE", "#DIV/0!"},
+		{"图表之后"},
+	}
+	if got := sheet.Rows(); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+	if got := sheet.NumFmt(4, 1); got != 2 {
+		t.Errorf("NumFmt(4, 1) = %d, want 2", got)
+	}
+	if got := sheet.NumFmt(9, 9); got != 0 {
+		t.Errorf("NumFmt(9, 9) = %d, want 0 out of sheet", got)
+	}
+}
+
+func TestSheets(t *testing.T) {
+	wb := openSample(t, "sheets.xls")
+	type sheet struct {
+		name    string
+		visible bool
+		rows    [][]string
+	}
+	want := []sheet{
+		{"显示", true, [][]string{{"a"}}},
+		{"隐藏", false, [][]string{{"b"}}},
+		{"VeryHidden", false, [][]string{{"c"}}},
+	}
+	got := make([]sheet, 0)
+	for _, s := range wb.Sheets() {
+		got = append(got, sheet{s.Name, s.Visible, s.Rows()})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sheets = %+v, want %+v", got, want)
+	}
+	if wb.Sheet("Chart1") != nil {
+		t.Error("chart sheet is read as worksheet")
+	}
+}
+
+func TestDates(t *testing.T) {
+	wb := openSample(t, "dates.xls")
+	want := []string{
+		"09-12-21",           // 14 mm-dd-yy
+		"9/12/21 12:00",      // 22 m/d/yy h:mm
+		"2021/9/12",          // 31 chinese yyyy"年"m"月"d"日"
+		"2021/9/12",          // custom date
+		"18:00:00",           // custom time
+		"2021/9/12 06:00:00", // custom date and time
+		"1234.50",            // 4 #,##0.00
+		"12.34%",             // 10 0.00%
+		"3.14159",            // [Red] is not a date
+		"Sep-21",             // 17 mmm-yy
+		"02-28-00",           // before the 1900-02-29 excel counts
+		"03-01-00",
+	}
+	if got := wb.Sheet("日期").Rows(); len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+	for col, numFmt := range []int{14, 22, 31, 164, 165, 166, 4, 10, 167, 17} {
+		if got := wb.Sheet("日期").NumFmt(col+1, 1); got != numFmt {
+			t.Errorf("NumFmt(%d, 1) = %d, want %d", col+1, got, numFmt)
+		}
+	}
+
+	wb = openSample(t, "date1904.xls")
+	if got := wb.Sheet("Sheet1").Rows(); !reflect.DeepEqual(got, [][]string{{"09-12-21"}}) {
+		t.Errorf("rows of 1904 date system = %q, want 09-12-21", got)
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	book := sampleBook{xfs: []uint16{0}, sheets: []sampleSheet{{name: "Sheet1"}}}
+	stream := book.stream()
+	biff5 := append([]byte{}, stream...)
+	biff5[5] = 0x05 // version of BOF, 0x0500 of excel 5.0/95
+	encrypted := join(stream[:len(bof(bofGlobals))], rec(recFilePass, u16(0)), stream[len(bof(bofGlobals)):])
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    error
+	}{
+		{"biff5", compoundFile(biff5), ErrNotBIFF8},
+		{"encrypted", compoundFile(encrypted), ErrEncrypted},
+	}
+	for _, tt := range tests {
+		if _, err := OpenBytes(tt.content); err != tt.want {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if _, err := OpenBytes([]byte("not a compound file")); err == nil {
+		t.Error("no error for file which is not xls")
+	}
+}
+
+// TestSSTCounts check counts read from the file are not trusted to allocate
+func TestSSTCounts(t *testing.T) {
+	huge := u32(0xFFFFFFFF)
+	// declared much more strings than written
+	sst, err := readSST([][]byte{join(huge, huge, u16(1), []byte{0}, []byte("a"))})
+	if err != nil || !reflect.DeepEqual(sst, []string{"a"}) {
+		t.Errorf("sst = %q, %v, want a", sst, err)
+	}
+	// size of ext data larger than the record
+	if _, err := readSST([][]byte{join(u32(1), u32(1), u16(1), []byte{0x04}, huge, []byte("a"))}); err != errTruncated {
+		t.Errorf("err = %v, want %v", err, errTruncated)
+	}
+}